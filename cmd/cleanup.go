@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/jkroepke/kube-webhook-certgen/pkg/k8s"
+	"github.com/spf13/cobra"
+)
+
+const caBundleKeep = "keep"
+
+var cleanupCmd = &cobra.Command{
+	Use:     "cleanup",
+	Aliases: []string{"delete"},
+	Short:   "Delete the secret 'secret-name' in 'namespace' and optionally clear or release the caBundle of the configured objects",
+	Long:    "Meant for uninstall hooks, e.g. a Helm pre-delete hook. Deletes the secret 'secret-name' in 'namespace', so a reinstall generates a new ca instead of reusing an orphaned secret. With --ca-bundle clear, the caBundle is removed from the configured ValidatingWebhookConfiguration, MutatingWebhookConfiguration, APIService and CustomResourceDefinition. With --ca-bundle release, the caBundle is kept, but no longer owned by the kube-webhook-certgen field manager",
+	PreRunE: configureLogging,
+	RunE:    withPushedMetrics(cleanupCommand),
+}
+
+func cleanupCommand(_ *cobra.Command, _ []string) error {
+	switch cfg.caBundleAction {
+	case caBundleKeep, string(k8s.CleanupActionClear), string(k8s.CleanupActionRelease):
+	default:
+		return fmt.Errorf("invalid ca-bundle action '%s', must be 'keep', 'clear' or 'release'", cfg.caBundleAction)
+	}
+
+	clientSet, aggregatorClientSet, apiExtensionsClientSet, dynamicClient, err := newKubernetesClients(cfg.kubeconfig)
+	if err != nil {
+		return fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+
+	k, err := k8s.New(clientSet, aggregatorClientSet, apiExtensionsClientSet, dynamicClient)
+	if err != nil {
+		return fmt.Errorf("failed to create k8s helper: %w", err)
+	}
+
+	ctx := context.Background()
+
+	// The objects are cleaned up even if the secret can not be deleted and vice versa.
+	objectsErr := cleanupObjects(ctx, k)
+	secretErr := k.DeleteSecret(ctx, cfg.secretName, cfg.namespace)
+
+	if err = errors.Join(objectsErr, secretErr); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	slog.Info("successfully cleaned up")
+
+	return nil
+}
+
+// cleanupObjects applies --ca-bundle to the configured objects.
+func cleanupObjects(ctx context.Context, k *k8s.K8s) error {
+	patchConfig := newPatchConfig(k)
+	if cfg.caBundleAction == caBundleKeep || !patchConfig.hasTargets() {
+		return nil
+	}
+
+	// The ca is not needed to select the objects, and the secret may already be gone.
+	options, err := patchConfig.objectOptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err = k.CleanupObjects(ctx, options, k8s.CleanupAction(cfg.caBundleAction)); err != nil {
+		return fmt.Errorf("failed to clean up objects: %w", err)
+	}
+
+	return nil
+}
+
+//nolint:lll
+func init() {
+	rootCmd.AddCommand(cleanupCmd)
+	cleanupCmd.Flags().StringVar(&cfg.secretName, "secret-name", "", "Name of the secret to delete")
+	cleanupCmd.Flags().StringVar(&cfg.namespace, "namespace", "", "Namespace of the secret to delete")
+	cleanupCmd.Flags().StringVar(&cfg.caBundleAction, "ca-bundle", caBundleKeep, "What to do with the caBundle of the configured objects: keep|clear|release. clear removes the caBundle, release removes the ownership of the kube-webhook-certgen field manager and keeps the caBundle")
+	addObjectFlags(cleanupCmd.Flags())
+	addPushgatewayFlags(cleanupCmd.Flags())
+
+	_ = cleanupCmd.MarkFlagRequired("secret-name")
+	_ = cleanupCmd.MarkFlagRequired("namespace")
+}
@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/jkroepke/kube-webhook-certgen/pkg/certs"
+	"github.com/jkroepke/kube-webhook-certgen/pkg/controller"
+	"github.com/jkroepke/kube-webhook-certgen/pkg/k8s"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/uuid"
+)
+
+var controllerCmd = &cobra.Command{
+	Use:     "controller",
+	Short:   "Continuously keep the certificates in secret 'secret-name' in 'namespace' valid and the ca patched into the configured objects",
+	Long:    "Runs as a long-running process, e.g. in a Deployment. Watches the secret and the configured ValidatingWebhookConfiguration, MutatingWebhookConfiguration, APIService and CustomResourceDefinitions and re-applies the ca whenever it drifts",
+	PreRunE: validateCreateFlags,
+	RunE:    controllerCommand,
+}
+
+func controllerCommand(_ *cobra.Command, _ []string) error {
+	if err := configureSecretProfile(); err != nil {
+		return err
+	}
+
+	certOptions, err := newCertOptions()
+	if err != nil {
+		return err
+	}
+
+	clientSet, aggregatorClientSet, apiExtensionsClientSet, dynamicClient, err := newKubernetesClients(cfg.kubeconfig)
+	if err != nil {
+		return fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+
+	k, err := k8s.New(clientSet, aggregatorClientSet, apiExtensionsClientSet, dynamicClient)
+	if err != nil {
+		return fmt.Errorf("failed to create k8s helper: %w", err)
+	}
+
+	if err = detectLegacySecret(context.Background(), k); err != nil {
+		return err
+	}
+
+	defer configureEvents(k, clientSet)()
+
+	patchConfig := newPatchConfig(k)
+
+	options := controller.Options{
+		SecretName:                          cfg.secretName,
+		Namespace:                           cfg.namespace,
+		ValidatingWebhookConfigurationNames: patchConfig.validatingWebhookConfigurationNames(),
+		MutatingWebhookConfigurationNames:   patchConfig.mutatingWebhookConfigurationNames(),
+		APIServiceNames:                     patchConfig.APIServiceNames,
+		CustomResourceDefinitionNames:       patchConfig.CRDNames,
+		Discover:                            patchConfig.discover(),
+		LabelSelector:                       patchConfig.Selector,
+		ResyncPeriod:                        cfg.resyncPeriod,
+	}
+
+	c, err := controller.New(clientSet, aggregatorClientSet, apiExtensionsClientSet, options, func(ctx context.Context) error {
+		return reconcile(ctx, k, certOptions)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create controller: %w", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if err = serveMetrics(ctx); err != nil {
+		return err
+	}
+
+	if !cfg.leaderElect {
+		err = c.Run(ctx)
+	} else {
+		var leaderElectionOptions controller.LeaderElectionOptions
+
+		leaderElectionOptions, err = newLeaderElectionOptions()
+		if err != nil {
+			return err
+		}
+
+		err = c.RunWithLeaderElection(ctx, leaderElectionOptions)
+	}
+
+	if err != nil {
+		return fmt.Errorf("controller failed: %w", err)
+	}
+
+	return nil
+}
+
+// newLeaderElectionOptions returns the leader election options from the command line flags.
+func newLeaderElectionOptions() (controller.LeaderElectionOptions, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return controller.LeaderElectionOptions{}, fmt.Errorf("failed to get hostname: %w", err)
+	}
+
+	options := controller.LeaderElectionOptions{
+		LeaseName:      cfg.leaderElectionLeaseName,
+		LeaseNamespace: cfg.leaderElectionNamespace,
+		Identity:       hostname + "_" + string(uuid.NewUUID()),
+		LeaseDuration:  cfg.leaderElectionLeaseDuration,
+		RenewDeadline:  cfg.leaderElectionRenewDeadline,
+		RetryPeriod:    cfg.leaderElectionRetryPeriod,
+	}
+
+	if options.LeaseNamespace == "" {
+		options.LeaseNamespace = cfg.namespace
+	}
+
+	return options, nil
+}
+
+// reconcile makes sure the secret holds valid certificates and patches the ca into all objects which do not carry it.
+func reconcile(ctx context.Context, k *k8s.K8s, certOptions certs.Options) error {
+	certificates, err := createCertificates(ctx, k, certOptions)
+	if err != nil {
+		return err
+	}
+
+	if err = publishCA(ctx, k, certificates); err != nil {
+		return err
+	}
+
+	config := newPatchConfig(k)
+	config.CABundle = certificates.CA
+
+	options, err := config.patchOptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	inSync, err := k.CABundleInSync(ctx, options)
+	if err != nil {
+		return fmt.Errorf("failed to check caBundle: %w", err)
+	}
+
+	if inSync {
+		slog.DebugContext(ctx, "caBundle is in sync")
+		certgenMetrics.ReconcileSucceeded()
+
+		return nil
+	}
+
+	slog.InfoContext(ctx, "caBundle drift detected, patching objects")
+
+	start := time.Now()
+	result, err := k.PatchObjects(ctx, options)
+	logPatchResult(ctx, result)
+	certgenMetrics.ObservePatchResult(result)
+	certgenMetrics.ObserveStep("patch", time.Since(start))
+
+	if err != nil {
+		return fmt.Errorf("failed to patch objects: %w", err)
+	}
+
+	slog.InfoContext(ctx, "successfully patched webhooks")
+	certgenMetrics.ReconcileSucceeded()
+
+	return nil
+}
+
+//nolint:lll
+func init() {
+	rootCmd.AddCommand(controllerCmd)
+	addCreateFlags(controllerCmd.Flags())
+	addPatchFlags(controllerCmd.Flags())
+	addMetricsFlags(controllerCmd.Flags())
+	addEventsFlag(controllerCmd.Flags())
+	controllerCmd.Flags().DurationVar(&cfg.resyncPeriod, "resync-period", time.Hour, "Interval in which the certificates and all objects are checked even if nothing changed")
+	controllerCmd.Flags().BoolVar(&cfg.leaderElect, "leader-elect", false, "If true, use a Lease to elect a single replica which generates certificates and patches objects")
+	controllerCmd.Flags().StringVar(&cfg.leaderElectionLeaseName, "leader-election-lease-name", "kube-webhook-certgen", "Name of the Lease used for leader election")
+	controllerCmd.Flags().StringVar(&cfg.leaderElectionNamespace, "leader-election-namespace", "", "Namespace of the Lease used for leader election. Defaults to --namespace")
+	controllerCmd.Flags().DurationVar(&cfg.leaderElectionLeaseDuration, "leader-election-lease-duration", 15*time.Second, "Duration non-leader replicas wait before taking over an unrenewed Lease")
+	controllerCmd.Flags().DurationVar(&cfg.leaderElectionRenewDeadline, "leader-election-renew-deadline", 10*time.Second, "Duration the leader retries renewing the Lease before giving up leadership")
+	controllerCmd.Flags().DurationVar(&cfg.leaderElectionRetryPeriod, "leader-election-retry-period", 2*time.Second, "Duration replicas wait between attempts to acquire or renew the Lease")
+
+	_ = controllerCmd.MarkFlagRequired("host")
+	_ = controllerCmd.MarkFlagRequired("secret-name")
+	_ = controllerCmd.MarkFlagRequired("namespace")
+
+	controllerCmd.MarkFlagsRequiredTogether("ca-cert-file", "ca-key-file")
+	controllerCmd.MarkFlagsRequiredTogether("secret-owner-api-version", "secret-owner-kind", "secret-owner-name")
+	controllerCmd.MarkFlagsMutuallyExclusive("ca-secret-name", "ca-cert-file")
+}
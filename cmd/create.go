@@ -1,71 +1,707 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/jkroepke/kube-webhook-certgen/pkg/certs"
+	"github.com/jkroepke/kube-webhook-certgen/pkg/files"
 	"github.com/jkroepke/kube-webhook-certgen/pkg/k8s"
+	"github.com/jkroepke/kube-webhook-certgen/pkg/metrics"
+	"github.com/jkroepke/kube-webhook-certgen/pkg/storage"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 var create = &cobra.Command{
 	Use:     "create",
 	Short:   "Generate a ca and server cert+key and store the results in a secret 'secret-name' in 'namespace'",
 	Long:    "Generate a ca and server cert+key and store the results in a secret 'secret-name' in 'namespace'",
-	PreRunE: configureLogging,
-	RunE:    createCommand,
+	PreRunE: validateCreateFlags,
+	RunE:    withPushedMetrics(createCommand),
+}
+
+// CreateConfig configures Create.
+type CreateConfig struct {
+	// Store holds the certificates. Existing certificates are loaded from it and renewed certificates are saved to it.
+	Store storage.Store
+	// CA signs the certificates. If nil, a new ca is generated for every new certificate bundle.
+	CA          *certs.CA
+	CertOptions certs.Options
+	Host        string
+	RenewBefore time.Duration
+	// CARolloverGracePeriod enables an overlapping ca rollover if the ca expires within RenewBefore: a new ca is added
+	// to the ca, the certificate is issued by the new ca after the grace period and the old ca is dropped after another
+	// grace period. The store must keep k8s.Certificates.CAKey and Rollover. If zero, the ca is replaced at once.
+	CARolloverGracePeriod time.Duration
+	// DryRun is one of none, client or server. In client and server mode, the changes are written to DiffOutput
+	// if Store implements storage.Differ. In client mode, nothing is saved. The server mode relies on Store
+	// to send server-side dry run requests.
+	DryRun     string
+	DiffOutput io.Writer
+	// Metrics records the expiry of the returned certificates. If nil, nothing is recorded. Failures are logged only.
+	Metrics *metrics.Metrics
+}
+
+// Create makes sure the store holds a valid certificate bundle for the configured host
+// and returns the certificates held by the store.
+func Create(ctx context.Context, cfg *CreateConfig) (*k8s.Certificates, error) {
+	if cfg.Store == nil {
+		return nil, errors.New("no store defined")
+	}
+
+	start := time.Now()
+	certIssuer := &issuer{ca: cfg.CA, options: cfg.CertOptions, rolloverGracePeriod: cfg.CARolloverGracePeriod}
+
+	var newCerts *k8s.Certificates
+
+	existing, err := cfg.Store.Load(ctx)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		slog.InfoContext(ctx, "creating new certificates")
+
+		newCerts, err = certIssuer.generate(cfg.Host)
+	case err != nil:
+		return nil, fmt.Errorf("failed to load certificates: %w", err)
+	default:
+		cfg.recordExpiry(ctx, existing)
+
+		newCerts, err = certIssuer.renew(existing, cfg.Host, cfg.RenewBefore)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificates: %w", err)
+	}
+
+	if isDryRun(cfg.DryRun) {
+		if err = cfg.printDiff(ctx, newCerts); err != nil {
+			return nil, err
+		}
+	}
+
+	if newCerts == nil {
+		return cfg.observe(ctx, existing, start), nil
+	}
+
+	if cfg.DryRun == dryRunClient {
+		return cfg.observe(ctx, newCerts, start), nil
+	}
+
+	if err = cfg.Store.Save(ctx, newCerts); err != nil {
+		return nil, fmt.Errorf("failed to save certificates: %w", err)
+	}
+
+	return cfg.observe(ctx, newCerts, start), nil
+}
+
+// recordExpiry records a k8s.ReasonCertificateExpiringSoon Event on the store if the existing certificates expire
+// within RenewBefore. Nothing is recorded in a dry run or if the store does not implement storage.EventRecorder.
+func (cfg *CreateConfig) recordExpiry(ctx context.Context, existing *k8s.Certificates) {
+	recorder, ok := cfg.Store.(storage.EventRecorder)
+	if !ok || isDryRun(cfg.DryRun) {
+		return
+	}
+
+	bundle, err := certs.ParseBundle(existing.CA, existing.Cert, existing.Key)
+	if err != nil || !bundle.NeedsRenewal(cfg.RenewBefore) {
+		return
+	}
+
+	message := fmt.Sprintf("Certificates expire at %s, within %s, renewing", bundle.NotAfter().Format(time.RFC3339), cfg.RenewBefore)
+	if cfg.CA != nil && bundle.CANeedsRenewal(cfg.RenewBefore) {
+		message = fmt.Sprintf("The configured ca expires at %s, within %s, and must be renewed by its owner",
+			bundle.CA.NotAfter.Format(time.RFC3339), cfg.RenewBefore)
+	}
+
+	recorder.RecordEvent(ctx, corev1.EventTypeWarning, k8s.ReasonCertificateExpiringSoon, message)
+}
+
+// observe records the expiry of certificates and the duration since start in Metrics and returns certificates.
+// Metrics never fail Create, so a failure to record them is only logged.
+func (cfg *CreateConfig) observe(ctx context.Context, certificates *k8s.Certificates, start time.Time) *k8s.Certificates {
+	cfg.Metrics.ObserveStep("create", time.Since(start))
+
+	if err := cfg.Metrics.ObserveCertificates(certificates.CA, certificates.Cert, certificates.Key); err != nil {
+		slog.ErrorContext(ctx, "failed to record metrics", slog.Any("err", err))
+	}
+
+	return certificates
+}
+
+// printDiff writes the changes saving newCerts would apply to DiffOutput, if the store supports it.
+func (cfg *CreateConfig) printDiff(ctx context.Context, newCerts *k8s.Certificates) error {
+	differ, ok := cfg.Store.(storage.Differ)
+	if !ok {
+		slog.InfoContext(ctx, "dry run, the store does not support printing changes")
+
+		return nil
+	}
+
+	diff, err := differ.Diff(ctx, newCerts)
+	if err != nil {
+		return fmt.Errorf("failed to diff certificates: %w", err)
+	}
+
+	output := cfg.DiffOutput
+	if output == nil {
+		output = io.Discard
+	}
+
+	return printDiffs(output, diff)
 }
 
 func createCommand(_ *cobra.Command, _ []string) error {
-	clientSet, aggregatorClientSet, err := newKubernetesClients(cfg.kubeconfig)
+	if err := configureSecretProfile(); err != nil {
+		return err
+	}
+
+	certOptions, err := newCertOptions()
 	if err != nil {
-		return fmt.Errorf("failed to create kubernetes client: %w", err)
+		return err
 	}
 
-	k, err := k8s.New(clientSet, aggregatorClientSet)
+	fileOptions, err := newFileOptions()
 	if err != nil {
-		slog.Error("failed to create k8s helper", slog.Any("err", err))
+		return err
 	}
 
 	ctx := context.TODO()
 
-	_, err = k.GetCaFromSecret(ctx, "ca.crt", cfg.secretName, cfg.namespace)
+	var k *k8s.K8s
+
+	if cfg.secretName != "" {
+		clientSet, aggregatorClientSet, apiExtensionsClientSet, dynamicClient, err := newKubernetesClients(cfg.kubeconfig)
+		if err != nil {
+			return fmt.Errorf("failed to create kubernetes client: %w", err)
+		}
+
+		k, err = k8s.New(clientSet, aggregatorClientSet, apiExtensionsClientSet, dynamicClient)
+		if err != nil {
+			return fmt.Errorf("failed to create k8s helper: %w", err)
+		}
+
+		if err = configureDryRun(k); err != nil {
+			return err
+		}
+
+		if err = detectLegacySecret(ctx, k); err != nil {
+			return err
+		}
+
+		defer configureEvents(k, clientSet)()
+	} else if err = validateOfflineCreate(); err != nil {
+		return err
+	}
+
+	stores := newCreateStores(k, fileOptions)
+
+	config, err := newCreateConfig(ctx, k, stores[0], certOptions)
+	if err != nil {
+		return err
+	}
+
+	certificates, err := Create(ctx, config)
+	if err != nil {
+		return err
+	}
+
+	if err = publishCA(ctx, k, certificates); err != nil {
+		return err
+	}
+
+	if isDryRun(cfg.dryRun) {
+		return nil
+	}
+
+	for _, store := range stores[1:] {
+		if err = saveIfChanged(ctx, store, certificates); err != nil {
+			return fmt.Errorf("failed to save certificates: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// saveIfChanged saves certificates to store unless it already holds them. Secondary stores are thus only written
+// when Create issued new certificates or when they are missing or out of date, e.g. after --output-dir was added.
+func saveIfChanged(ctx context.Context, store storage.Store, certificates *k8s.Certificates) error {
+	if stored, err := store.Load(ctx); err == nil && sameCertificates(stored, certificates) {
+		return nil
+	}
+
+	return store.Save(ctx, certificates) //nolint:wrapcheck
+}
+
+// sameCertificates reports whether a and b hold the same certificates, ca key and rollover state.
+func sameCertificates(a, b *k8s.Certificates) bool {
+	return bytes.Equal(a.CA, b.CA) && bytes.Equal(a.Cert, b.Cert) && bytes.Equal(a.Key, b.Key) && bytes.Equal(a.CAKey, b.CAKey) &&
+		maps.Equal(k8s.RolloverAnnotations(a.Rollover), k8s.RolloverAnnotations(b.Rollover))
+}
+
+// validateOfflineCreate validates the flags of create if the API server is not accessed.
+func validateOfflineCreate() error {
+	if err := validateDryRun(); err != nil {
+		return err
+	}
+
+	if cfg.dryRun == dryRunServer {
+		return errors.New("dry-run=server requires secret-name")
+	}
+
+	if cfg.caSecretName != "" {
+		return errors.New("ca-secret-name requires secret-name. Use ca-cert-file and ca-key-file to sign with an existing ca without API server access")
+	}
+
+	if cfg.caConfigMapName != "" {
+		return errors.New("ca-configmap-name requires secret-name")
+	}
+
+	return nil
+}
+
+// publishCA saves the ca to the ConfigMap --ca-configmap-name in each of --ca-configmap-namespace.
+// Unchanged ConfigMaps are not updated, so it is safe to call on every run. k may be nil if no ConfigMap is configured.
+// In a dry run, the changes are printed and, in client mode, nothing is saved.
+func publishCA(ctx context.Context, k *k8s.K8s, certificates *k8s.Certificates) error {
+	if cfg.caConfigMapName == "" {
+		return nil
+	}
+
+	namespaces := uniqueNames(cfg.caConfigMapNamespaces)
+	if len(namespaces) == 0 {
+		namespaces = []string{cfg.namespace}
+	}
+
+	for _, namespace := range namespaces {
+		store := storage.NewConfigMap(k, cfg.caConfigMapName, namespace, cfg.caConfigMapKeys)
+
+		if isDryRun(cfg.dryRun) {
+			diff, err := store.Diff(ctx, certificates)
+			if err != nil {
+				return fmt.Errorf("failed to diff ConfigMap '%s' in namespace '%s': %w", cfg.caConfigMapName, namespace, err)
+			}
+
+			if err = printDiffs(rootCmd.OutOrStdout(), diff); err != nil {
+				return err
+			}
+
+			if cfg.dryRun == dryRunClient {
+				continue
+			}
+		}
+
+		if err := store.Save(ctx, certificates); err != nil {
+			return fmt.Errorf("failed to publish ca to ConfigMap '%s' in namespace '%s': %w", cfg.caConfigMapName, namespace, err)
+		}
+	}
+
+	return nil
+}
+
+// newCreateStores returns the stores selected by --secret-name, --output-dir and --stdout, in this order.
+// Certificates are loaded from the first store and additionally saved to all others.
+func newCreateStores(k *k8s.K8s, fileOptions files.Options) []storage.Store {
+	var stores []storage.Store
+
+	if k != nil {
+		stores = append(stores, newSecretStore(k))
+	}
+
+	if cfg.outputDir != "" {
+		stores = append(stores, storage.NewFiles(fileOptions))
+	}
+
+	if cfg.stdout {
+		stores = append(stores, storage.NewWriter(rootCmd.OutOrStdout()))
+	}
+
+	return stores
+}
+
+// newSecretStore returns the store for the secret configured by --secret-name and --namespace.
+func newSecretStore(k *k8s.K8s) *storage.Secret {
+	keys := k8s.SecretKeys{CA: cfg.caName, Cert: cfg.certName, Key: cfg.keyName, CAKey: cfg.caKeyName}
+
+	return storage.NewSecret(k, cfg.secretName, cfg.secretType, cfg.namespace, keys, newSecretMetadata())
+}
+
+// newSecretMetadata returns the labels, annotations and owner of the secret from the command line flags.
+func newSecretMetadata() k8s.SecretMetadata {
+	metadata := k8s.SecretMetadata{
+		Labels:      cfg.secretLabels,
+		Annotations: cfg.secretAnnotations,
+	}
+
+	if cfg.secretOwnerAPIVersion != "" || cfg.secretOwnerKind != "" || cfg.secretOwnerName != "" {
+		metadata.Owner = &k8s.ObjectReference{
+			APIVersion: cfg.secretOwnerAPIVersion,
+			Kind:       cfg.secretOwnerKind,
+			Name:       cfg.secretOwnerName,
+		}
+	}
+
+	return metadata
+}
+
+// newCreateConfig returns the configuration of Create from the command line flags. k may be nil if --ca-secret-name is not set.
+func newCreateConfig(ctx context.Context, k *k8s.K8s, store storage.Store, certOptions certs.Options) (*CreateConfig, error) {
+	ca, err := loadCA(ctx, k)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CreateConfig{
+		Store:                 store,
+		CA:                    ca,
+		CertOptions:           certOptions,
+		Host:                  cfg.host,
+		RenewBefore:           cfg.renewBefore,
+		CARolloverGracePeriod: cfg.caRolloverGracePeriod,
+		DryRun:                cfg.dryRun,
+		DiffOutput:            rootCmd.OutOrStdout(),
+		Metrics:               certgenMetrics,
+	}, nil
+}
+
+// newFileOptions returns the options of the files written to --output-dir.
+func newFileOptions() (files.Options, error) {
+	fileOptions := files.DefaultOptions(cfg.outputDir)
+
+	certMode, err := strconv.ParseUint(cfg.certFileMode, 8, 32)
+	if err != nil || certMode > 0o777 {
+		return files.Options{}, fmt.Errorf("invalid cert-file-mode '%s', must be an octal file mode like 0644", cfg.certFileMode)
+	}
+
+	keyMode, err := strconv.ParseUint(cfg.keyFileMode, 8, 32)
+	if err != nil || keyMode > 0o777 {
+		return files.Options{}, fmt.Errorf("invalid key-file-mode '%s', must be an octal file mode like 0600", cfg.keyFileMode)
+	}
+
+	fileOptions.CertMode = fs.FileMode(certMode)
+	fileOptions.KeyMode = fs.FileMode(keyMode)
+
+	return fileOptions, nil
+}
+
+// validateCreateFlags configures logging and validates the flags of commands that create certificates,
+// before any certificate is generated or object is changed.
+func validateCreateFlags(cmd *cobra.Command, args []string) error {
+	if err := configureLogging(cmd, args); err != nil {
+		return err
+	}
+
+	return validateCAConfigMap()
+}
+
+// validateCAConfigMap validates --ca-configmap-key. The API server rejects a ConfigMap with an invalid key,
+// which would otherwise only fail after the secret was written.
+func validateCAConfigMap() error {
+	if cfg.caConfigMapName != "" && len(cfg.caConfigMapKeys) == 0 {
+		return errors.New("ca-configmap-name requires a ca-configmap-key")
+	}
+
+	for _, key := range cfg.caConfigMapKeys {
+		if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+			return fmt.Errorf("invalid ca-configmap-key '%s': %s", key, strings.Join(errs, ", "))
+		}
+	}
+
+	return nil
+}
+
+// newCertOptions returns the validated certificate options from the command line flags.
+func newCertOptions() (certs.Options, error) {
+	certOptions := certs.Options{
+		KeyType:      certs.KeyType(cfg.keyType),
+		CALifetime:   cfg.caLifetime,
+		LeafLifetime: cfg.certLifetime,
+		ClockSkew:    cfg.clockSkew,
+	}
+
+	if err := certOptions.Validate(); err != nil {
+		return certs.Options{}, fmt.Errorf("invalid certificate options: %w", err)
+	}
+
+	if err := validateRenewBefore(); err != nil {
+		return certs.Options{}, err
+	}
+
+	if err := validateCARollover(); err != nil {
+		return certs.Options{}, err
+	}
+
+	return certOptions, nil
+}
+
+// validateRenewBefore validates --renew-before against --cert-lifetime. A certificate which expires within
+// --renew-before right after it was issued would be reissued on every run.
+func validateRenewBefore() error {
 	switch {
-	case errors.Is(err, k8s.ErrNoSecret):
-		slog.Info("creating new secret")
+	case cfg.renewBefore <= 0:
+		return fmt.Errorf("renew-before %s must be positive", cfg.renewBefore)
+	case cfg.renewBefore >= cfg.certLifetime:
+		return fmt.Errorf("renew-before %s must be shorter than cert-lifetime %s, otherwise the certificate is reissued on every run",
+			cfg.renewBefore, cfg.certLifetime)
+	}
+
+	return nil
+}
+
+// createCertificates makes sure the secret holds a valid certificate bundle for the configured hosts
+// and returns the certificates stored in the secret.
+func createCertificates(ctx context.Context, k *k8s.K8s, certOptions certs.Options) (*k8s.Certificates, error) {
+	config, err := newCreateConfig(ctx, k, newSecretStore(k), certOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	certificates, err := Create(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("secret '%s' in namespace '%s': %w", cfg.secretName, cfg.namespace, err)
+	}
+
+	return certificates, nil
+}
+
+// issuer issues certificates, either with a generated ca or with the ca configured by --ca-secret-name or --ca-cert-file.
+type issuer struct {
+	// ca is the externally provided ca. If nil, a new ca is generated for every new certificate bundle.
+	ca      *certs.CA
+	options certs.Options
+	// rolloverGracePeriod enables overlapping ca rollovers, see CreateConfig.CARolloverGracePeriod.
+	rolloverGracePeriod time.Duration
+}
 
-		newCa, newCert, newKey, err := certs.GenerateCerts(cfg.host)
+// generate issues a new certificate bundle for hosts.
+func (i *issuer) generate(hosts string) (*k8s.Certificates, error) {
+	if i.ca != nil {
+		return i.sign(i.ca, hosts)
+	}
+
+	ca, err := certs.GenerateCA(i.options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ca: %w", err)
+	}
+
+	return i.sign(ca, hosts)
+}
+
+// sign signs a leaf certificate for hosts with ca. The ca key is only returned for generated cas.
+func (i *issuer) sign(ca *certs.CA, hosts string) (*k8s.Certificates, error) {
+	cert, key, err := ca.SignLeaf(hosts, i.options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate certs: %w", err)
+	}
+
+	newCerts := &k8s.Certificates{CA: ca.CertPEM, Cert: cert, Key: key}
+	if ca != i.ca {
+		newCerts.CAKey = ca.KeyPEM
+	}
+
+	return newCerts, nil
+}
+
+// renew returns new certificates if the existing ones are about to expire, do not match hosts
+// or were not issued by the configured ca. It returns nil if the existing certificates can be kept.
+func (i *issuer) renew(existing *k8s.Certificates, hosts string, renewBefore time.Duration) (*k8s.Certificates, error) {
+	bundle, err := certs.ParseBundle(existing.CA, existing.Cert, existing.Key)
+	if err != nil {
+		return nil, fmt.Errorf("existing certificate bundle is invalid: %w", err)
+	}
+
+	if i.ca != nil && bundle.CANeedsRenewal(renewBefore) {
+		// The configured ca can not be renewed here. Certificates are still reissued for new hosts.
+		slog.Warn("the configured ca expires soon and must be renewed by its owner",
+			slog.Time("not_after", bundle.CA.NotAfter),
+			slog.Duration("renew_before", renewBefore),
+		)
+	}
+
+	switch {
+	case i.ca == nil && existing.Rollover.InProgress():
+		return i.continueRollover(existing, bundle, hosts)
+	case i.ca == nil && i.rolloverGracePeriod > 0 && bundle.CANeedsRenewal(renewBefore):
+		return i.startRollover(existing)
+	case i.ca == nil && bundle.CANeedsRenewal(renewBefore):
+		slog.Info("ca expires soon, rotating secret",
+			slog.Time("not_after", bundle.CA.NotAfter),
+			slog.Duration("renew_before", renewBefore),
+		)
+
+		return i.generate(hosts)
+	case i.ca != nil && !bytes.Equal(existing.CA, i.ca.CertPEM):
+		slog.Info("certificate was not issued by the configured ca, reissuing certificate")
+
+		return i.generate(hosts)
+	case bundle.CertNeedsRenewal(renewBefore) && i.canExtend(bundle):
+		slog.Info("certificate expires soon, reissuing certificate with the existing ca",
+			slog.Time("not_after", bundle.Cert.NotAfter),
+			slog.Duration("renew_before", renewBefore),
+		)
+
+		return i.reissue(existing, hosts)
+	case !bundle.MatchesHosts(hosts):
+		slog.Info("certificate does not match requested hosts, reissuing certificate",
+			slog.Any("dns_names", bundle.Cert.DNSNames),
+			slog.Any("ip_addresses", bundle.Cert.IPAddresses),
+			slog.String("hosts", hosts),
+		)
+
+		return i.reissue(existing, hosts)
+	default:
+		slog.Info("existing certificates are still valid",
+			slog.Time("not_after", bundle.NotAfter()),
+		)
+
+		return nil, nil //nolint:nilnil
+	}
+}
+
+// canExtend reports whether reissuing the certificate of bundle extends its lifetime. Certificates never outlive
+// their ca, so a certificate which expires with the configured ca is kept until the ca is replaced.
+func (i *issuer) canExtend(bundle *certs.Bundle) bool {
+	return i.ca == nil || bundle.Cert.NotAfter.Before(i.ca.Cert.NotAfter)
+}
+
+// reissue signs a new leaf certificate for hosts with the existing ca, keeping caBundle valid.
+// If the ca key is not available, a new ca is generated instead.
+func (i *issuer) reissue(existing *k8s.Certificates, hosts string) (*k8s.Certificates, error) {
+	if i.ca != nil {
+		return i.sign(i.ca, hosts)
+	}
+
+	if len(existing.CAKey) == 0 {
+		slog.Warn("the store holds no ca key, replacing the ca instead of reissuing the certificate. "+
+			"The caBundle of the patched objects must be updated, and clients trusting only the old ca fail until then",
+			slog.String("hosts", hosts),
+		)
+
+		return i.generate(hosts)
+	}
+
+	ca, err := certs.LoadCA(existing.CA, existing.CAKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ca from secret: %w", err)
+	}
+
+	return i.sign(ca, hosts)
+}
+
+// loadCA loads the ca configured by --ca-secret-name or --ca-cert-file and --ca-key-file.
+// It returns nil if no ca is configured.
+func loadCA(ctx context.Context, k *k8s.K8s) (*certs.CA, error) {
+	var caCert, caKey []byte
+
+	switch {
+	case cfg.caSecretName != "":
+		namespace := cfg.caSecretNamespace
+		if namespace == "" {
+			namespace = cfg.namespace
+		}
+
+		var err error
+
+		caCert, caKey, err = k.GetKeyPairFromSecret(ctx, cfg.caSecretName, namespace, cfg.caSecretCertName, cfg.caSecretKeyName)
 		if err != nil {
-			return fmt.Errorf("failed to generate certs: %w", err)
+			return nil, fmt.Errorf("failed to get ca from secret '%s' in namespace '%s': %w", cfg.caSecretName, namespace, err)
 		}
+	case cfg.caCertFile != "":
+		var err error
 
-		err = k.SaveCertsToSecret(ctx, cfg.secretName, cfg.secretType, cfg.namespace, cfg.caName, cfg.certName, cfg.keyName, newCa, newCert, newKey)
+		caCert, err = os.ReadFile(filepath.Clean(cfg.caCertFile))
 		if err != nil {
-			return fmt.Errorf("failed to save certs to secret: %w", err)
+			return nil, fmt.Errorf("failed to read ca certificate: %w", err)
+		}
+
+		caKey, err = os.ReadFile(filepath.Clean(cfg.caKeyFile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca key: %w", err)
 		}
-	case err != nil:
-		return fmt.Errorf("failed to get secret: %w", err)
 	default:
-		slog.Info("secret already exists")
+		return nil, nil //nolint:nilnil
 	}
 
-	return nil
+	ca, err := certs.LoadCA(caCert, caKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ca: %w", err)
+	}
+
+	slog.Info("using provided ca to sign certificates",
+		slog.String("subject", ca.Cert.Subject.String()),
+		slog.Time("not_after", ca.Cert.NotAfter),
+	)
+
+	return ca, nil
 }
 
+//nolint:lll
 func init() {
 	rootCmd.AddCommand(create)
-	create.Flags().StringVar(&cfg.host, "host", "", "Comma-separated hostnames and IPs to generate a certificate for")
-	create.Flags().StringVar(&cfg.secretName, "secret-name", "", "Name of the secret where certificate information will be written")
-	create.Flags().StringVar(&cfg.secretType, "secret-type", "Opaque", "Type of the secret where certificate information will be written")
-	create.Flags().StringVar(&cfg.namespace, "namespace", "", "Namespace of the secret where certificate information will be written")
-	create.Flags().StringVar(&cfg.caName, "ca-name", "ca", "Name of ca file in the secret")
-	create.Flags().StringVar(&cfg.certName, "cert-name", "cert", "Name of cert file in the secret")
-	create.Flags().StringVar(&cfg.keyName, "key-name", "key", "Name of key file in the secret")
+	addCreateFlags(create.Flags())
+	addDryRunFlag(create.Flags())
+	addEventsFlag(create.Flags())
+	addPushgatewayFlags(create.Flags())
+	create.Flags().StringVar(&cfg.outputDir, "output-dir", "", "Directory to write ca.crt, tls.crt, tls.key and, if known, the ca key to ca.key. If --secret-name is not set, the certificates are only written to this directory and the API server is not accessed")
+	create.Flags().StringVar(&cfg.certFileMode, "cert-file-mode", "0644", "Octal file mode of ca.crt and tls.crt in --output-dir")
+	create.Flags().StringVar(&cfg.keyFileMode, "key-file-mode", "0600", "Octal file mode of tls.key and ca.key in --output-dir")
+	create.Flags().BoolVar(&cfg.stdout, "stdout", false, "If true, also print the PEM encoded ca, certificate and key to stdout. If neither --secret-name nor --output-dir is set, new certificates are generated on every run")
 
 	_ = create.MarkFlagRequired("host")
-	_ = create.MarkFlagRequired("secret-name")
-	_ = create.MarkFlagRequired("namespace")
+
+	create.MarkFlagsOneRequired("secret-name", "output-dir", "stdout")
+	create.MarkFlagsRequiredTogether("secret-name", "namespace")
+
+	create.MarkFlagsRequiredTogether("ca-cert-file", "ca-key-file")
+	create.MarkFlagsRequiredTogether("secret-owner-api-version", "secret-owner-kind", "secret-owner-name")
+	create.MarkFlagsMutuallyExclusive("ca-secret-name", "ca-cert-file")
+}
+
+// addCreateFlags adds the flags controlling certificate generation and the secret they are stored in.
+//
+//nolint:lll
+func addCreateFlags(flags *pflag.FlagSet) {
+	defaults := certs.DefaultOptions()
+
+	flags.StringVar(&cfg.host, "host", "", "Comma-separated hostnames and IPs to generate a certificate for")
+	flags.StringVar(&cfg.secretName, "secret-name", "", "Name of the secret where certificate information will be written")
+	flags.StringVar(&cfg.secretType, "secret-type", "", "Type of the secret where certificate information will be written. Defaults to the type of --secret-profile")
+	flags.StringVar(&cfg.namespace, "namespace", "", "Namespace of the secret where certificate information will be written")
+	flags.StringVar(&cfg.caName, "ca-name", "", "Name of ca file in the secret. Defaults to the ca key name of --secret-profile")
+	flags.StringVar(&cfg.certName, "cert-name", "", "Name of cert file in the secret. Defaults to the cert key name of --secret-profile")
+	flags.StringVar(&cfg.keyName, "key-name", "", "Name of key file in the secret. Defaults to the key key name of --secret-profile")
+	addSecretProfileFlag(flags)
+	flags.StringToStringVar(&cfg.secretLabels, "secret-label", nil, "Label of the secret as key=value. May be repeated or comma-separated")
+	flags.StringToStringVar(&cfg.secretAnnotations, "secret-annotation", nil, "Annotation of the secret as key=value. May be repeated or comma-separated")
+	flags.StringVar(&cfg.secretOwnerAPIVersion, "secret-owner-api-version", "", "API version of the owner of the secret, e.g. apps/v1. If set with --secret-owner-kind and --secret-owner-name, the secret is deleted by the garbage collector together with its owner")
+	flags.StringVar(&cfg.secretOwnerKind, "secret-owner-kind", "", "Kind of the owner of the secret, e.g. Deployment or ValidatingWebhookConfiguration")
+	flags.StringVar(&cfg.secretOwnerName, "secret-owner-name", "", "Name of the owner of the secret. Namespaced owners must be in --namespace")
+	flags.StringVar(&cfg.caKeyName, "ca-key-name", "", "Name of ca key file in the secret. If set, the ca key is stored to reissue an expiring certificate without replacing the ca or patching the objects. Defaults to the ca key name of --secret-profile")
+	flags.StringVar(&cfg.caSecretName, "ca-secret-name", "", "Name of a secret holding an existing ca to sign the certificate with, instead of generating a ca")
+	flags.StringVar(&cfg.caSecretNamespace, "ca-secret-namespace", "", "Namespace of the secret holding the existing ca. Defaults to --namespace")
+	flags.StringVar(&cfg.caSecretCertName, "ca-secret-cert-name", "tls.crt", "Name of ca certificate file in the ca secret. May contain the full ca chain, starting with the signing ca")
+	flags.StringVar(&cfg.caSecretKeyName, "ca-secret-key-name", "tls.key", "Name of ca key file in the ca secret")
+	flags.StringVar(&cfg.caCertFile, "ca-cert-file", "", "Path to a PEM encoded ca certificate to sign the certificate with, instead of generating a ca. May contain the full ca chain, starting with the signing ca")
+	flags.StringVar(&cfg.caKeyFile, "ca-key-file", "", "Path to the PEM encoded key of --ca-cert-file")
+	flags.StringVar(&cfg.keyType, "key-type", string(defaults.KeyType), "Algorithm of the generated keys: ecdsa-p256|ecdsa-p384|rsa-2048|rsa-3072|rsa-4096|ed25519")
+	flags.DurationVar(&cfg.caLifetime, "ca-lifetime", defaults.CALifetime, "Validity of the generated ca")
+	flags.DurationVar(&cfg.certLifetime, "cert-lifetime", defaults.LeafLifetime, "Validity of the generated certificate. Must not exceed --ca-lifetime")
+	flags.DurationVar(&cfg.clockSkew, "clock-skew", defaults.ClockSkew, "Backdate the validity start of the generated certificates by this duration")
+	flags.StringVar(&cfg.caConfigMapName, "ca-configmap-name", "", "If set, also publish the ca, and only the ca, to a ConfigMap with this name, e.g. for clients which must not read the secret")
+	flags.StringSliceVar(&cfg.caConfigMapKeys, "ca-configmap-key", []string{"ca.crt"}, "Key of the ca in the ConfigMap. May be repeated to publish the ca under multiple keys")
+	flags.StringSliceVar(&cfg.caConfigMapNamespaces, "ca-configmap-namespace", nil, "Namespace of the ConfigMap. May be repeated to mirror the ConfigMap into multiple namespaces. Defaults to --namespace")
+	flags.DurationVar(&cfg.renewBefore, "renew-before", 30*24*time.Hour, "Regenerate the certificates if the ca or the certificate expires within this duration. Must be shorter than --cert-lifetime")
+	flags.DurationVar(&cfg.caRolloverGracePeriod, "ca-rollover-grace-period", 0, "If set, replace an expiring ca with an overlapping rollover: the caBundle carries the old and the new ca, the certificate is issued by the new ca after this duration and the old ca is dropped after another one. Requires --secret-name and a ca key name, see --ca-key-name. Must be less than half of --renew-before")
 }
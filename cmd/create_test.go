@@ -0,0 +1,402 @@
+package cmd_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jkroepke/kube-webhook-certgen/cmd"
+	"github.com/jkroepke/kube-webhook-certgen/pkg/certs"
+	"github.com/jkroepke/kube-webhook-certgen/pkg/files"
+	"github.com/jkroepke/kube-webhook-certgen/pkg/k8s"
+	"github.com/jkroepke/kube-webhook-certgen/pkg/metrics"
+	"github.com/jkroepke/kube-webhook-certgen/pkg/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Create(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.TODO()
+
+	t.Run("generates_certificates_if_store_is_empty", func(t *testing.T) {
+		t.Parallel()
+
+		s := &store{}
+		config := testCreateConfig(s)
+
+		created, err := cmd.Create(ctx, config)
+		require.NoError(t, err)
+		require.Equal(t, 1, s.saves)
+		require.Equal(t, created, s.certs)
+
+		bundle, err := certs.ParseBundle(created.CA, created.Cert, created.Key)
+		require.NoError(t, err)
+		require.True(t, bundle.MatchesHosts("localhost"))
+	})
+
+	t.Run("keeps_valid_certificates", func(t *testing.T) {
+		t.Parallel()
+
+		s := &store{}
+		config := testCreateConfig(s)
+
+		created, err := cmd.Create(ctx, config)
+		require.NoError(t, err)
+
+		kept, err := cmd.Create(ctx, config)
+		require.NoError(t, err)
+		require.Equal(t, created, kept)
+		require.Equal(t, 1, s.saves)
+	})
+
+	t.Run("reissues_certificate_with_stored_ca_on_host_change", func(t *testing.T) {
+		t.Parallel()
+
+		s := &store{}
+		config := testCreateConfig(s)
+
+		created, err := cmd.Create(ctx, config)
+		require.NoError(t, err)
+
+		config.Host = "localhost,example.com"
+
+		reissued, err := cmd.Create(ctx, config)
+		require.NoError(t, err)
+		require.Equal(t, 2, s.saves)
+		require.Equal(t, created.CA, reissued.CA)
+		require.NotEqual(t, created.Cert, reissued.Cert)
+	})
+
+	t.Run("reissues_expiring_certificate_with_stored_ca", func(t *testing.T) {
+		t.Parallel()
+
+		s := &store{}
+		config := testCreateConfig(s)
+		config.CertOptions.LeafLifetime = 2 * time.Hour
+		config.RenewBefore = 3 * time.Hour
+
+		created, err := cmd.Create(ctx, config)
+		require.NoError(t, err)
+
+		reissued, err := cmd.Create(ctx, config)
+		require.NoError(t, err)
+		require.Equal(t, 2, s.saves)
+		require.Equal(t, created.CA, reissued.CA)
+		require.Equal(t, created.CAKey, reissued.CAKey)
+		require.NotEqual(t, created.Cert, reissued.Cert)
+	})
+
+	t.Run("records_certificate_expiry", func(t *testing.T) {
+		t.Parallel()
+
+		s := &store{}
+		config := testCreateConfig(s)
+		config.Metrics = metrics.New()
+
+		created, err := cmd.Create(ctx, config)
+		require.NoError(t, err)
+
+		bundle, err := certs.ParseBundle(created.CA, created.Cert, created.Key)
+		require.NoError(t, err)
+
+		var b strings.Builder
+
+		_, err = config.Metrics.WriteTo(&b)
+		require.NoError(t, err)
+		require.Contains(t, b.String(), fmt.Sprintf("kube_webhook_certgen_cert_not_after_timestamp_seconds %d\n", bundle.Cert.NotAfter.Unix()))
+	})
+
+	t.Run("records_expiring_certificates", func(t *testing.T) {
+		t.Parallel()
+
+		s := &recorderStore{}
+		config := testCreateConfig(s)
+
+		_, err := cmd.Create(ctx, config)
+		require.NoError(t, err)
+		require.Empty(t, s.events)
+
+		config.RenewBefore = 48 * time.Hour
+
+		_, err = cmd.Create(ctx, config)
+		require.NoError(t, err)
+		require.Equal(t, []string{"Warning " + k8s.ReasonCertificateExpiringSoon}, s.events)
+		require.Equal(t, 2, s.saves)
+	})
+
+	t.Run("rolls_over_expiring_ca", func(t *testing.T) {
+		t.Parallel()
+
+		s := &store{}
+		config := testCreateConfig(s)
+		config.CertOptions.CALifetime = 2 * time.Hour
+		config.CertOptions.LeafLifetime = time.Hour
+		config.RenewBefore = 3 * time.Hour
+		config.CARolloverGracePeriod = time.Nanosecond
+
+		created, err := cmd.Create(ctx, config)
+		require.NoError(t, err)
+		require.Nil(t, created.Rollover)
+
+		bundled, err := cmd.Create(ctx, config)
+		require.NoError(t, err)
+		require.Equal(t, k8s.RolloverPhaseCABundled, bundled.Rollover.Phase)
+		require.True(t, bytes.HasSuffix(bundled.CA, created.CA))
+		require.Equal(t, created.Cert, bundled.Cert)
+
+		newCA := bytes.TrimSuffix(bundled.CA, created.CA)
+
+		rotated, err := cmd.Create(ctx, config)
+		require.NoError(t, err)
+		require.Equal(t, k8s.RolloverPhaseLeafRotated, rotated.Rollover.Phase)
+		require.Equal(t, bundled.CA, rotated.CA)
+
+		_, err = certs.ParseBundle(newCA, rotated.Cert, rotated.Key)
+		require.NoError(t, err)
+
+		completed, err := cmd.Create(ctx, config)
+		require.NoError(t, err)
+		require.Equal(t, k8s.RolloverPhaseCompleted, completed.Rollover.Phase)
+		require.Equal(t, newCA, completed.CA)
+		require.Equal(t, rotated.Cert, completed.Cert)
+		require.Equal(t, 4, s.saves)
+	})
+
+	t.Run("waits_for_ca_rollover_grace_period", func(t *testing.T) {
+		t.Parallel()
+
+		s := &store{}
+		config := testCreateConfig(s)
+		config.CertOptions.CALifetime = 2 * time.Hour
+		config.CertOptions.LeafLifetime = time.Hour
+		config.RenewBefore = 3 * time.Hour
+		config.CARolloverGracePeriod = time.Hour
+
+		_, err := cmd.Create(ctx, config)
+		require.NoError(t, err)
+
+		bundled, err := cmd.Create(ctx, config)
+		require.NoError(t, err)
+		require.Equal(t, k8s.RolloverPhaseCABundled, bundled.Rollover.Phase)
+
+		kept, err := cmd.Create(ctx, config)
+		require.NoError(t, err)
+		require.Equal(t, bundled, kept)
+		require.Equal(t, 2, s.saves)
+	})
+
+	t.Run("keeps_grace_period_of_left_over_ca_rollover", func(t *testing.T) {
+		t.Parallel()
+
+		s := &store{}
+		config := testCreateConfig(s)
+		config.CertOptions.CALifetime = 2 * time.Hour
+		config.CertOptions.LeafLifetime = time.Hour
+		config.RenewBefore = 3 * time.Hour
+		config.CARolloverGracePeriod = time.Hour
+
+		_, err := cmd.Create(ctx, config)
+		require.NoError(t, err)
+
+		bundled, err := cmd.Create(ctx, config)
+		require.NoError(t, err)
+		require.Equal(t, time.Hour, bundled.Rollover.GracePeriod)
+
+		// Disabling the rollover must not rush the phases of the one in progress.
+		config.CARolloverGracePeriod = 0
+
+		kept, err := cmd.Create(ctx, config)
+		require.NoError(t, err)
+		require.Equal(t, bundled, kept)
+
+		s.certs.Rollover.GracePeriod = 0
+
+		_, err = cmd.Create(ctx, config)
+		require.ErrorContains(t, err, "grace period is unknown")
+	})
+
+	t.Run("reissues_certificate_for_new_hosts_during_ca_rollover", func(t *testing.T) {
+		t.Parallel()
+
+		s := &store{}
+		config := testCreateConfig(s)
+		config.CertOptions.CALifetime = 2 * time.Hour
+		config.CertOptions.LeafLifetime = time.Hour
+		config.RenewBefore = 3 * time.Hour
+		config.CARolloverGracePeriod = time.Hour
+
+		_, err := cmd.Create(ctx, config)
+		require.NoError(t, err)
+
+		bundled, err := cmd.Create(ctx, config)
+		require.NoError(t, err)
+		require.Equal(t, k8s.RolloverPhaseCABundled, bundled.Rollover.Phase)
+
+		config.Host = "localhost,example.com"
+
+		reissued, err := cmd.Create(ctx, config)
+		require.NoError(t, err)
+		require.Equal(t, bundled.CA, reissued.CA)
+		require.Equal(t, bundled.Rollover, reissued.Rollover, "the phase must not change before its grace period")
+
+		bundle, err := certs.ParseBundle(reissued.CA, reissued.Cert, reissued.Key)
+		require.NoError(t, err)
+		require.True(t, bundle.MatchesHosts(config.Host))
+		require.Equal(t, 3, s.saves)
+	})
+
+	t.Run("reissues_certificate_with_ca_key_from_files", func(t *testing.T) {
+		t.Parallel()
+
+		options := files.DefaultOptions(t.TempDir())
+		config := testCreateConfig(storage.NewFiles(options))
+		config.CertOptions.LeafLifetime = 2 * time.Hour
+
+		created, err := cmd.Create(ctx, config)
+		require.NoError(t, err)
+		require.NotEmpty(t, created.CAKey)
+
+		config.RenewBefore = 3 * time.Hour
+
+		reissued, err := cmd.Create(ctx, config)
+		require.NoError(t, err)
+		require.Equal(t, created.CA, reissued.CA)
+		require.NotEqual(t, created.Cert, reissued.Cert)
+
+		// Without the ca key, the ca can only be replaced.
+		require.NoError(t, os.Remove(filepath.Join(options.Dir, options.CAKeyName)))
+
+		replaced, err := cmd.Create(ctx, config)
+		require.NoError(t, err)
+		require.NotEqual(t, created.CA, replaced.CA)
+		require.NotEmpty(t, replaced.CAKey)
+	})
+
+	t.Run("keeps_certificate_of_expiring_configured_ca", func(t *testing.T) {
+		t.Parallel()
+
+		s := &recorderStore{}
+		config := testCreateConfig(s)
+		config.RenewBefore = 3 * time.Hour
+
+		caOptions := certs.DefaultOptions()
+		caOptions.CALifetime = 2 * time.Hour
+		caOptions.LeafLifetime = time.Hour
+
+		ca, err := certs.GenerateCA(caOptions)
+		require.NoError(t, err)
+
+		config.CA = ca
+
+		created, err := cmd.Create(ctx, config)
+		require.NoError(t, err)
+
+		// The configured ca can not be renewed, so the certificate it issued is kept.
+		kept, err := cmd.Create(ctx, config)
+		require.NoError(t, err)
+		require.Equal(t, created, kept)
+		require.Equal(t, 1, s.saves)
+		require.Equal(t, []string{"Warning " + k8s.ReasonCertificateExpiringSoon}, s.events)
+	})
+
+	t.Run("does_not_save_on_client_dry_run", func(t *testing.T) {
+		t.Parallel()
+
+		var output bytes.Buffer
+
+		s := &differStore{}
+		config := testCreateConfig(s)
+		config.DryRun = "client"
+		config.DiffOutput = &output
+
+		created, err := cmd.Create(ctx, config)
+		require.NoError(t, err)
+		require.NotNil(t, created)
+		require.Zero(t, s.saves)
+		require.True(t, strings.HasPrefix(output.String(), "Secret test/test:\n"), output.String())
+	})
+
+	t.Run("returns_error_if_no_store_is_defined", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := cmd.Create(ctx, testCreateConfig(nil))
+		require.Error(t, err)
+	})
+}
+
+func testCreateConfig(s storage.Store) *cmd.CreateConfig {
+	options := certs.DefaultOptions()
+	options.LeafLifetime = 24 * time.Hour
+
+	return &cmd.CreateConfig{
+		Store:       s,
+		CertOptions: options,
+		Host:        "localhost",
+		RenewBefore: time.Hour,
+	}
+}
+
+// store keeps certificates in memory.
+type store struct {
+	certs *k8s.Certificates
+	saves int
+	// loadCA overrides LoadCA, if set.
+	loadCA func(context.Context) ([]byte, error)
+}
+
+func (s *store) Load(context.Context) (*k8s.Certificates, error) {
+	if s.certs == nil {
+		return nil, storage.ErrNotFound
+	}
+
+	return s.certs, nil
+}
+
+func (s *store) LoadCA(ctx context.Context) ([]byte, error) {
+	if s.loadCA != nil {
+		return s.loadCA(ctx)
+	}
+
+	if s.certs == nil {
+		return nil, storage.ErrNotFound
+	}
+
+	return s.certs.CA, nil
+}
+
+func (s *store) Save(_ context.Context, certs *k8s.Certificates) error {
+	s.certs = certs
+	s.saves++
+
+	return nil
+}
+
+// differStore is a store implementing storage.Differ.
+type differStore struct {
+	store
+}
+
+func (s *differStore) Diff(_ context.Context, certs *k8s.Certificates) (k8s.ObjectDiff, error) {
+	diff := k8s.ObjectDiff{Kind: "Secret", Namespace: "test", Name: "test"}
+	if certs != nil {
+		diff.Changes = []k8s.Change{{Field: "data[tls.crt]", Old: "<none>", New: "new"}}
+	}
+
+	return diff, nil
+}
+
+// recorderStore records Events like storage.Secret.
+type recorderStore struct {
+	store
+	events []string
+}
+
+func (s *recorderStore) RecordEvent(_ context.Context, eventType, reason, _ string) {
+	s.events = append(s.events, eventType+" "+reason)
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/jkroepke/kube-webhook-certgen/pkg/k8s"
+	"github.com/spf13/pflag"
+)
+
+const (
+	dryRunNone   = "none"
+	dryRunClient = "client"
+	dryRunServer = "server"
+)
+
+// addDryRunFlag adds the flag to print the changes instead of applying them.
+//
+//nolint:lll
+func addDryRunFlag(flags *pflag.FlagSet) {
+	flags.StringVar(&cfg.dryRun, "dry-run", dryRunNone, "Print the changes per object instead of applying them: none|client|server. server additionally sends all requests as server-side dry run")
+}
+
+// isDryRun reports whether changes are only printed.
+func isDryRun(mode string) bool {
+	return mode == dryRunClient || mode == dryRunServer
+}
+
+// validateDryRun validates --dry-run.
+func validateDryRun() error {
+	switch cfg.dryRun {
+	case "", dryRunNone, dryRunClient, dryRunServer:
+		return nil
+	default:
+		return fmt.Errorf("invalid dry-run mode '%s', must be 'none', 'client' or 'server'", cfg.dryRun)
+	}
+}
+
+// configureDryRun validates --dry-run and enables the server-side dry run on k if requested.
+func configureDryRun(k *k8s.K8s) error {
+	if err := validateDryRun(); err != nil {
+		return err
+	}
+
+	switch cfg.dryRun {
+	case dryRunClient:
+		slog.Info("client dry run, changes are printed but not sent to the API server")
+	case dryRunServer:
+		slog.Info("server dry run, changes are printed and validated by the API server but not persisted")
+		k.EnableServerDryRun()
+	}
+
+	return nil
+}
+
+// printDiffs writes the diffs to w.
+func printDiffs(w io.Writer, diffs ...k8s.ObjectDiff) error {
+	for _, diff := range diffs {
+		if _, err := io.WriteString(w, diff.String()); err != nil {
+			return fmt.Errorf("failed to print diff: %w", err)
+		}
+	}
+
+	return nil
+}
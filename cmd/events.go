@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/jkroepke/kube-webhook-certgen/pkg/k8s"
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	// eventFlushTimeout limits the time spent waiting for recorded Events to be written before a command returns.
+	eventFlushTimeout = 5 * time.Second
+	// eventFlushReason is the reason of the marker Event recorded to flush the Events. It is never written.
+	eventFlushReason = "KubeWebhookCertgenFlush"
+)
+
+// flushingEventSink writes Events to EventSink, except for the marker Event recorded when the Events are flushed.
+// The broadcaster hands Events to the sink one at a time in the order they were recorded, so once the marker arrives,
+// all Events recorded before it have been written or dropped, e.g. as repeated Events by the spam filter of the broadcaster.
+type flushingEventSink struct {
+	record.EventSink
+	flushed chan struct{}
+}
+
+func (s *flushingEventSink) Create(event *corev1.Event) (*corev1.Event, error) {
+	if event.Reason == eventFlushReason {
+		close(s.flushed)
+
+		return event, nil
+	}
+
+	return s.EventSink.Create(event) //nolint:wrapcheck
+}
+
+// configureEvents makes k record Events if --record-events is set. The returned function waits up to eventFlushTimeout
+// for the recorded Events to be written and must be called before the command returns.
+func configureEvents(k *k8s.K8s, clientSet kubernetes.Interface) func() {
+	if !cfg.recordEvents {
+		return func() {}
+	}
+
+	sink := &flushingEventSink{
+		EventSink: &typedcorev1.EventSinkImpl{Interface: clientSet.CoreV1().Events("")},
+		flushed:   make(chan struct{}),
+	}
+
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(sink)
+
+	hostname, _ := os.Hostname()
+	recorder := broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "kube-webhook-certgen", Host: hostname})
+
+	k.SetEventRecorder(recorder)
+
+	return func() {
+		// The marker refers to an object of its own, so the spam filter never drops it.
+		recorder.Event(&corev1.ObjectReference{Name: eventFlushReason}, corev1.EventTypeNormal, eventFlushReason, "")
+
+		select {
+		case <-sink.flushed:
+		case <-time.After(eventFlushTimeout):
+			slog.Warn("timed out writing Events", slog.Duration("timeout", eventFlushTimeout))
+		}
+
+		broadcaster.Shutdown()
+	}
+}
+
+// addEventsFlag adds the flag enabling Events.
+//
+//nolint:lll
+func addEventsFlag(flags *pflag.FlagSet) {
+	flags.BoolVar(&cfg.recordEvents, "record-events", false, "If true, record Kubernetes Events such as CertificateIssued, CertificateExpiringSoon, CABundleInjected and PatchFailed on the secret and the patched objects. Requires create and patch on events in the core API group in --namespace and in default")
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jkroepke/kube-webhook-certgen/pkg/k8s"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	aggregatorfake "k8s.io/kube-aggregator/pkg/client/clientset_generated/clientset/fake"
+)
+
+func TestConfigureEventsFlushesDroppedEvents(t *testing.T) {
+	cfg.recordEvents = true
+
+	t.Cleanup(func() { cfg.recordEvents = false })
+
+	clientSet := fake.NewClientset()
+
+	k, err := k8s.New(clientSet, aggregatorfake.NewSimpleClientset(), apiextensionsfake.NewSimpleClientset(), dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()))
+	require.NoError(t, err)
+
+	flush := configureEvents(k, clientSet)
+
+	// The same Event is aggregated into a single Event and, beyond the burst of the spam filter, dropped.
+	for range 30 {
+		k.RecordSecretEvent(t.Context(), "webhook-certs", "default", corev1.EventTypeNormal, k8s.ReasonCertificateIssued, "issued")
+	}
+
+	start := time.Now()
+
+	flush()
+
+	require.Less(t, time.Since(start), eventFlushTimeout/2)
+
+	events, err := clientSet.CoreV1().Events("default").List(t.Context(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, events.Items, 1)
+	require.Equal(t, k8s.ReasonCertificateIssued, events.Items[0].Reason)
+	require.Greater(t, events.Items[0].Count, int32(1))
+}
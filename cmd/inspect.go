@@ -0,0 +1,267 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jkroepke/kube-webhook-certgen/pkg/certs"
+	"github.com/jkroepke/kube-webhook-certgen/pkg/k8s"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+var inspectCmd = &cobra.Command{
+	Use:     "inspect",
+	Aliases: []string{"status"},
+	Short:   "Print the certificates in secret 'secret-name' in 'namespace' and whether the configured objects carry its ca",
+	Long:    "Read-only. Prints subject, SANs, issuer, validity, key type and fingerprints of the ca and the certificate in secret 'secret-name' in 'namespace' and reports for every configured ValidatingWebhookConfiguration, MutatingWebhookConfiguration, APIService and CustomResourceDefinition whether its caBundle matches the ca",
+	PreRunE: configureLogging,
+	RunE:    inspectCommand,
+}
+
+// inspectReport is the output of the inspect command. The json field names are part of the output format.
+type inspectReport struct {
+	Secret  secretReport   `json:"secret"`
+	Objects []objectReport `json:"objects,omitempty"`
+}
+
+type secretReport struct {
+	Name      string      `json:"name"`
+	Namespace string      `json:"namespace"`
+	CA        *certs.Info `json:"ca"`
+	Cert      *certs.Info `json:"cert"`
+	// Valid reports whether the key matches the certificate and the certificate is signed by the ca.
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+}
+
+type objectReport struct {
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	Webhook string `json:"webhook,omitempty"`
+	Field   string `json:"field,omitempty"`
+	InSync  bool   `json:"inSync"`
+	Error   string `json:"error,omitempty"`
+}
+
+func inspectCommand(cmd *cobra.Command, _ []string) error {
+	if err := configureSecretProfile(); err != nil {
+		return err
+	}
+
+	switch cfg.output {
+	case "text", "json", "yaml":
+	default:
+		return fmt.Errorf("invalid output format '%s', must be 'text', 'json' or 'yaml'", cfg.output)
+	}
+
+	clientSet, aggregatorClientSet, apiExtensionsClientSet, dynamicClient, err := newKubernetesClients(cfg.kubeconfig)
+	if err != nil {
+		return fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+
+	k, err := k8s.New(clientSet, aggregatorClientSet, apiExtensionsClientSet, dynamicClient)
+	if err != nil {
+		return fmt.Errorf("failed to create k8s helper: %w", err)
+	}
+
+	if err = detectLegacySecret(context.Background(), k); err != nil {
+		return err
+	}
+
+	report, err := inspect(context.Background(), k)
+	if err != nil {
+		return err
+	}
+
+	return writeInspectReport(cmd.OutOrStdout(), cfg.output, report)
+}
+
+// inspect reads the secret and the configured objects and returns their state. A secret that cannot be read
+// is reported in the secret report, and the objects are still checked.
+func inspect(ctx context.Context, k *k8s.K8s) (*inspectReport, error) {
+	report := &inspectReport{Secret: secretReport{Name: cfg.secretName, Namespace: cfg.namespace}}
+
+	ca, err := inspectSecret(ctx, k, &report.Secret)
+	if err != nil {
+		report.Secret.Error = err.Error()
+	}
+
+	patchConfig := newPatchConfig(k)
+	if !patchConfig.hasTargets() {
+		return report, nil
+	}
+
+	options, err := patchConfig.objectOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	options.CABundle = ca
+
+	statuses, err := k.InspectCABundles(ctx, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect objects: %w", err)
+	}
+
+	for _, status := range statuses {
+		object := objectReport{
+			Kind:    status.Kind,
+			Name:    status.Name,
+			Webhook: status.Webhook,
+			Field:   status.Field,
+			// Without a ca, no object is in sync, not even one with an empty caBundle.
+			InSync: status.InSync && len(ca) > 0,
+		}
+
+		if status.Err != nil {
+			object.Error = status.Err.Error()
+		}
+
+		report.Objects = append(report.Objects, object)
+	}
+
+	return report, nil
+}
+
+// inspectSecret describes the certificates in the secret in report. It returns the ca, which is also returned
+// with an error if only the certificate is missing or invalid.
+func inspectSecret(ctx context.Context, k *k8s.K8s, report *secretReport) ([]byte, error) {
+	ca, err := k.GetCaFromSecret(ctx, cfg.caName, cfg.secretName, cfg.namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ca from secret '%s' in namespace '%s': %w", cfg.secretName, cfg.namespace, err)
+	}
+
+	if report.CA, err = certs.Describe(ca); err != nil {
+		return ca, fmt.Errorf("failed to parse ca: %w", err)
+	}
+
+	cert, key, err := k.GetKeyPairFromSecret(ctx, cfg.secretName, cfg.namespace, cfg.certName, cfg.keyName)
+	if err != nil {
+		return ca, fmt.Errorf("failed to get certificate from secret '%s' in namespace '%s': %w", cfg.secretName, cfg.namespace, err)
+	}
+
+	if report.Cert, err = certs.Describe(cert); err != nil {
+		return ca, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	if _, err = certs.ParseBundle(ca, cert, key); err != nil {
+		return ca, err //nolint:wrapcheck
+	}
+
+	report.Valid = true
+
+	return ca, nil
+}
+
+// writeInspectReport writes report to w in the given format: text, json or yaml.
+func writeInspectReport(w io.Writer, format string, report *inspectReport) error {
+	var (
+		out []byte
+		err error
+	)
+
+	switch format {
+	case "json":
+		out, err = json.MarshalIndent(report, "", "  ")
+		out = append(out, '\n')
+	case "yaml":
+		out, err = yaml.Marshal(report)
+	default:
+		out = []byte(formatInspectReport(report))
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+
+	if _, err = w.Write(out); err != nil {
+		return fmt.Errorf("failed to print report: %w", err)
+	}
+
+	return nil
+}
+
+// formatInspectReport formats report for humans.
+func formatInspectReport(report *inspectReport) string {
+	var b strings.Builder
+
+	state := "valid"
+	if !report.Secret.Valid {
+		state = "invalid: " + report.Secret.Error
+	}
+
+	fmt.Fprintf(&b, "Secret %s/%s: %s\n", report.Secret.Namespace, report.Secret.Name, state)
+	formatCertificateInfo(&b, "ca", report.Secret.CA)
+	formatCertificateInfo(&b, "cert", report.Secret.Cert)
+
+	if len(report.Objects) == 0 {
+		return b.String()
+	}
+
+	b.WriteString("Objects:\n")
+
+	for _, object := range report.Objects {
+		name := object.Name
+		if object.Field != "" {
+			name += " " + object.Field
+		}
+
+		switch {
+		case object.Error != "":
+			fmt.Fprintf(&b, "  %s %s: error: %s\n", object.Kind, name, object.Error)
+		case object.InSync:
+			fmt.Fprintf(&b, "  %s %s: in sync\n", object.Kind, name)
+		default:
+			fmt.Fprintf(&b, "  %s %s: out of sync\n", object.Kind, name)
+		}
+	}
+
+	return b.String()
+}
+
+func formatCertificateInfo(b *strings.Builder, title string, info *certs.Info) {
+	if info == nil {
+		return
+	}
+
+	fmt.Fprintf(b, "  %s:\n", title)
+	fmt.Fprintf(b, "    subject: %s\n", info.Subject)
+	fmt.Fprintf(b, "    issuer: %s\n", info.Issuer)
+	fmt.Fprintf(b, "    serial number: %s\n", info.SerialNumber)
+
+	if len(info.DNSNames) > 0 {
+		fmt.Fprintf(b, "    dns names: %s\n", strings.Join(info.DNSNames, ", "))
+	}
+
+	if len(info.IPAddresses) > 0 {
+		fmt.Fprintf(b, "    ip addresses: %s\n", strings.Join(info.IPAddresses, ", "))
+	}
+
+	fmt.Fprintf(b, "    not before: %s\n", info.NotBefore.Format(time.RFC3339))
+	fmt.Fprintf(b, "    not after: %s\n", info.NotAfter.Format(time.RFC3339))
+	fmt.Fprintf(b, "    key type: %s\n", info.KeyType)
+	fmt.Fprintf(b, "    sha1 fingerprint: %s\n", info.SHA1Fingerprint)
+	fmt.Fprintf(b, "    sha256 fingerprint: %s\n", info.SHA256Fingerprint)
+}
+
+//nolint:lll
+func init() {
+	rootCmd.AddCommand(inspectCmd)
+	inspectCmd.Flags().StringVar(&cfg.secretName, "secret-name", "", "Name of the secret where certificate information will be read from")
+	inspectCmd.Flags().StringVar(&cfg.namespace, "namespace", "", "Namespace of the secret where certificate information will be read from")
+	inspectCmd.Flags().StringVar(&cfg.secretType, "secret-type", "", "Type of the secret where certificate information will be read from. Defaults to the type of --secret-profile")
+	inspectCmd.Flags().StringVar(&cfg.caName, "ca-name", "", "Name of ca file in the secret. Defaults to the ca key name of --secret-profile")
+	inspectCmd.Flags().StringVar(&cfg.certName, "cert-name", "", "Name of cert file in the secret. Defaults to the cert key name of --secret-profile")
+	inspectCmd.Flags().StringVar(&cfg.keyName, "key-name", "", "Name of key file in the secret. Defaults to the key key name of --secret-profile")
+	addSecretProfileFlag(inspectCmd.Flags())
+	inspectCmd.Flags().StringVarP(&cfg.output, "output", "o", "text", "Output format: text|json|yaml")
+	addObjectFlags(inspectCmd.Flags())
+
+	_ = inspectCmd.MarkFlagRequired("secret-name")
+	_ = inspectCmd.MarkFlagRequired("namespace")
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/jkroepke/kube-webhook-certgen/pkg/metrics"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// pushTimeout limits the time spent pushing metrics to --pushgateway-url.
+const pushTimeout = 10 * time.Second
+
+// certgenMetrics collects the metrics of the current process. They are served by --metrics-address
+// and pushed to --pushgateway-url.
+var certgenMetrics = metrics.New()
+
+// serveMetrics serves certgenMetrics on --metrics-address until ctx is cancelled. It does nothing if the flag is not set.
+// The listener is opened before returning, so an invalid address fails the command.
+func serveMetrics(ctx context.Context) error {
+	if cfg.metricsAddress == "" {
+		return nil
+	}
+
+	listener, err := (&net.ListenConfig{}).Listen(ctx, "tcp", cfg.metricsAddress)
+	if err != nil {
+		return fmt.Errorf("failed to listen on metrics address '%s': %w", cfg.metricsAddress, err)
+	}
+
+	slog.InfoContext(ctx, "serving metrics", slog.String("address", listener.Addr().String()))
+
+	go func() {
+		if err := certgenMetrics.Serve(ctx, listener); err != nil {
+			slog.ErrorContext(ctx, "metrics listener failed", slog.Any("err", err))
+		}
+	}()
+
+	return nil
+}
+
+// withPushedMetrics wraps the RunE of a command which runs once, like create, patch, run and cleanup, to push
+// certgenMetrics and its outcome when it finishes. Invalid flags or arguments fail before RunE, so they are not pushed.
+func withPushedMetrics(runE func(*cobra.Command, []string) error) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		start := time.Now()
+		err := runE(cmd, args)
+
+		pushMetrics(cmd, err, time.Since(start))
+
+		return err
+	}
+}
+
+// pushMetrics pushes certgenMetrics and the outcome of cmd to --pushgateway-url. It does nothing if the flag is not set
+// or cmd is nil. The metrics are grouped by --pushgateway-job and the name of cmd. A failed push is logged, but does not fail cmd.
+func pushMetrics(cmd *cobra.Command, err error, duration time.Duration) {
+	if cfg.pushgatewayURL == "" || cmd == nil {
+		return
+	}
+
+	certgenMetrics.RunFinished(err, duration)
+
+	ctx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+	defer cancel()
+
+	grouping := map[string]string{"command": cmd.Name()}
+
+	if err := certgenMetrics.Push(ctx, http.DefaultClient, cfg.pushgatewayURL, cfg.pushgatewayJob, grouping); err != nil {
+		slog.Error("failed to push metrics", slog.Any("err", err))
+
+		return
+	}
+
+	slog.Debug("pushed metrics", slog.String("url", cfg.pushgatewayURL))
+}
+
+// addMetricsFlags adds the flags configuring the metrics listener.
+//
+//nolint:lll
+func addMetricsFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&cfg.metricsAddress, "metrics-address", "", "If set, serve Prometheus metrics on /metrics of this address, e.g. :8080")
+}
+
+// addPushgatewayFlags adds the flags configuring the push of the metrics at the end of a command wrapped by withPushedMetrics.
+//
+//nolint:lll
+func addPushgatewayFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&cfg.pushgatewayURL, "pushgateway-url", "", "If set, push the metrics to this Pushgateway when the command finishes, e.g. http://pushgateway:9091")
+	flags.StringVar(&cfg.pushgatewayJob, "pushgateway-job", "kube-webhook-certgen", "Job the metrics are pushed as. The metrics are grouped by job and command, so use a job per release")
+}
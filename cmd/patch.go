@@ -4,47 +4,162 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"slices"
+	"time"
 
 	"github.com/jkroepke/kube-webhook-certgen/pkg/k8s"
+	"github.com/jkroepke/kube-webhook-certgen/pkg/metrics"
+	"github.com/jkroepke/kube-webhook-certgen/pkg/storage"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	admissionv1 "k8s.io/api/admissionregistration/v1"
 )
 
 var patch = &cobra.Command{
 	Use:     "patch",
-	Short:   "Patch a ValidatingWebhookConfiguration, MutatingWebhookConfiguration or APIService 'object-name' by using the ca from 'secret-name' in 'namespace'",
-	Long:    "Patch a ValidatingWebhookConfiguration, MutatingWebhookConfiguration or APIService 'object-name' by using the ca from 'secret-name' in 'namespace'",
+	Short:   "Patch a ValidatingWebhookConfiguration, MutatingWebhookConfiguration, APIService or CustomResourceDefinition 'object-name' by using the ca from 'secret-name' in 'namespace'",
+	Long:    "Patch a ValidatingWebhookConfiguration, MutatingWebhookConfiguration, APIService or CustomResourceDefinition 'object-name' by using the ca from 'secret-name' in 'namespace'",
 	PreRunE: configureLogging,
-	RunE:    patchCommand,
+	RunE:    withPushedMetrics(patchCommand),
 }
 
 type PatchConfig struct {
-	Patcher            Patcher
+	Patcher Patcher
+	// CAStore holds the ca. It is only used if CABundle is empty.
+	CAStore storage.Store
+	// CABundle is patched into the objects. If empty, the ca is loaded from CAStore.
+	CABundle           []byte
 	PatchFailurePolicy string
-	APIServiceName     string
-	WebhookName        string
-	SecretName         string
-	CaName             string
-	Namespace          string
-	PatchMethod        string
-	PatchMutating      bool
-	PatchValidating    bool
+	APIServiceNames    []string
+	// WebhookNames are patched as ValidatingWebhookConfiguration and/or MutatingWebhookConfiguration,
+	// depending on PatchValidating and PatchMutating.
+	WebhookNames []string
+	// ValidatingWebhookNames and MutatingWebhookNames are always patched, regardless of PatchValidating and PatchMutating.
+	ValidatingWebhookNames []string
+	MutatingWebhookNames   []string
+	CRDNames               []string
+	// WebhookFilter selects the webhook entries within the webhook configurations that are patched.
+	WebhookFilter k8s.WebhookFilter
+	// Selector and InjectFromAnnotation additionally patch all objects found by discovery.
+	Selector             string
+	InjectFromAnnotation bool
+	SecretName           string
+	Namespace            string
+	PatchMethod          string
+	PatchMutating        bool
+	PatchValidating      bool
+	// DryRun is one of none, client or server. In client and server mode, the changes are written to DiffOutput
+	// before patching. In client mode, no object is patched.
+	DryRun     string
+	DiffOutput io.Writer
+	// Metrics records the outcome of patching each object. If nil, nothing is recorded.
+	Metrics *metrics.Metrics
 }
 
 type Patcher interface {
-	PatchObjects(ctx context.Context, options k8s.PatchOptions) error
-	GetCaFromSecret(ctx context.Context, caName, secretName, namespace string) ([]byte, error)
+	PatchObjects(ctx context.Context, options k8s.PatchOptions) (k8s.PatchResult, error)
+	DiscoverObjects(ctx context.Context, options k8s.DiscoveryOptions) (k8s.PatchOptions, error)
+	DiffObjects(ctx context.Context, options k8s.PatchOptions) ([]k8s.ObjectDiff, error)
 }
 
-//nolint:cyclop
 func Patch(ctx context.Context, cfg *PatchConfig) error {
 	if cfg.Patcher == nil {
 		return errors.New("no patcher defined")
 	}
 
-	if !cfg.PatchMutating && !cfg.PatchValidating && cfg.APIServiceName == "" {
-		return errors.New("patch-validating=false, patch-mutating=false. You must patch at least one kind of webhook, otherwise this command is a no-op")
+	start := time.Now()
+
+	options, err := cfg.patchOptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	if isDryRun(cfg.DryRun) {
+		diffs, err := cfg.Patcher.DiffObjects(ctx, options)
+		if err != nil {
+			return fmt.Errorf("failed to diff objects: %w", err)
+		}
+
+		output := cfg.DiffOutput
+		if output == nil {
+			output = io.Discard
+		}
+
+		if err = printDiffs(output, diffs...); err != nil {
+			return err
+		}
+
+		if cfg.DryRun == dryRunClient {
+			return nil
+		}
+	}
+
+	result, err := cfg.Patcher.PatchObjects(ctx, options)
+	logPatchResult(ctx, result)
+	cfg.Metrics.ObservePatchResult(result)
+	cfg.Metrics.ObserveStep("patch", time.Since(start))
+
+	if err != nil {
+		return fmt.Errorf("failed to patch objects: %w", err)
+	}
+
+	return nil
+}
+
+// logPatchResult logs the outcome of patching each object.
+func logPatchResult(ctx context.Context, result k8s.PatchResult) {
+	for _, object := range result {
+		if object.Err != nil {
+			slog.ErrorContext(ctx, "failed to patch object",
+				slog.String("kind", object.Kind),
+				slog.String("name", object.Name),
+				slog.Any("err", object.Err),
+			)
+
+			continue
+		}
+
+		slog.InfoContext(ctx, "successfully patched object",
+			slog.String("kind", object.Kind),
+			slog.String("name", object.Name),
+		)
+	}
+}
+
+// patchOptions validates the configuration and returns the options for Patcher.PatchObjects.
+func (cfg *PatchConfig) patchOptions(ctx context.Context) (k8s.PatchOptions, error) {
+	options, err := cfg.objectOptions(ctx)
+	if err != nil {
+		return k8s.PatchOptions{}, err
+	}
+
+	ca := cfg.CABundle
+	if len(ca) == 0 {
+		if cfg.CAStore == nil {
+			return k8s.PatchOptions{}, errors.New("no ca store defined")
+		}
+
+		ca, err = cfg.CAStore.LoadCA(ctx)
+		if err != nil {
+			return k8s.PatchOptions{}, fmt.Errorf("failed to load ca: %w", err)
+		}
+
+		if len(ca) == 0 {
+			return k8s.PatchOptions{}, errors.New("loaded ca is empty")
+		}
+	}
+
+	options.CABundle = ca
+
+	return options, nil
+}
+
+// objectOptions validates the configuration and returns the options selecting the objects to patch. The ca is not set.
+func (cfg *PatchConfig) objectOptions(ctx context.Context) (k8s.PatchOptions, error) {
+	if !cfg.hasTargets() {
+		return k8s.PatchOptions{}, errors.New("patch-validating=false, patch-mutating=false. You must patch at least one kind of webhook, otherwise this command is a no-op")
 	}
 
 	var failurePolicy admissionv1.FailurePolicyType
@@ -55,65 +170,130 @@ func Patch(ctx context.Context, cfg *PatchConfig) error {
 	case "Fail":
 		failurePolicy = admissionv1.FailurePolicyType(cfg.PatchFailurePolicy)
 	default:
-		return fmt.Errorf("patch-failure-policy %s is not valid", cfg.PatchFailurePolicy)
+		return k8s.PatchOptions{}, fmt.Errorf("patch-failure-policy %s is not valid", cfg.PatchFailurePolicy)
 	}
 
-	ca, err := cfg.Patcher.GetCaFromSecret(ctx, cfg.CaName, cfg.SecretName, cfg.Namespace)
-	if err != nil {
-		return fmt.Errorf("failed to get ca from secret '%s' in namespace '%s': %w", cfg.SecretName, cfg.Namespace, err)
+	options := k8s.PatchOptions{
+		FailurePolicyType:                   failurePolicy,
+		ValidatingWebhookConfigurationNames: cfg.validatingWebhookConfigurationNames(),
+		MutatingWebhookConfigurationNames:   cfg.mutatingWebhookConfigurationNames(),
+		APIServiceNames:                     cfg.APIServiceNames,
+		CustomResourceDefinitionNames:       cfg.CRDNames,
+		PatchMethod:                         cfg.PatchMethod,
+		WebhookFilter:                       cfg.WebhookFilter,
 	}
 
-	if ca == nil {
-		return fmt.Errorf("no secret with '%s' in '%s'", cfg.SecretName, cfg.Namespace)
+	if cfg.discover() {
+		if err := cfg.addDiscoveredObjects(ctx, &options); err != nil {
+			return k8s.PatchOptions{}, err
+		}
 	}
 
-	options := k8s.PatchOptions{
-		CABundle:          ca,
-		FailurePolicyType: failurePolicy,
-		APIServiceName:    cfg.APIServiceName,
-		PatchMethod:       cfg.PatchMethod,
+	return options, nil
+}
+
+// hasTargets reports whether any kind of object is selected for patching.
+func (cfg *PatchConfig) hasTargets() bool {
+	return cfg.PatchMutating || cfg.PatchValidating || len(cfg.ValidatingWebhookNames) > 0 || len(cfg.MutatingWebhookNames) > 0 ||
+		len(cfg.APIServiceNames) > 0 || len(cfg.CRDNames) > 0 || cfg.discover()
+}
+
+// discover reports whether objects should be discovered by label selector or annotation.
+func (cfg *PatchConfig) discover() bool {
+	return cfg.Selector != "" || cfg.InjectFromAnnotation
+}
+
+// discoveryOptions returns the options selecting the objects found by discovery.
+func (cfg *PatchConfig) discoveryOptions() k8s.DiscoveryOptions {
+	options := k8s.DiscoveryOptions{LabelSelector: cfg.Selector}
+	if cfg.InjectFromAnnotation {
+		options.InjectFrom = cfg.Namespace + "/" + cfg.SecretName
 	}
 
-	if cfg.PatchMutating {
-		options.MutatingWebhookConfigurationName = cfg.WebhookName
+	return options
+}
+
+// addDiscoveredObjects adds the names of all discovered objects to options.
+// Discovered webhook configurations are subject to PatchValidating and PatchMutating.
+func (cfg *PatchConfig) addDiscoveredObjects(ctx context.Context, options *k8s.PatchOptions) error {
+	discovered, err := cfg.Patcher.DiscoverObjects(ctx, cfg.discoveryOptions())
+	if err != nil {
+		return fmt.Errorf("failed to discover objects: %w", err)
 	}
 
 	if cfg.PatchValidating {
-		options.ValidatingWebhookConfigurationName = cfg.WebhookName
+		options.ValidatingWebhookConfigurationNames = uniqueNames(options.ValidatingWebhookConfigurationNames, discovered.ValidatingWebhookConfigurationNames)
 	}
 
-	if err := cfg.Patcher.PatchObjects(ctx, options); err != nil {
-		return fmt.Errorf("failed to patch objects: %w", err)
+	if cfg.PatchMutating {
+		options.MutatingWebhookConfigurationNames = uniqueNames(options.MutatingWebhookConfigurationNames, discovered.MutatingWebhookConfigurationNames)
 	}
 
+	options.APIServiceNames = uniqueNames(options.APIServiceNames, discovered.APIServiceNames)
+	options.CustomResourceDefinitionNames = uniqueNames(options.CustomResourceDefinitionNames, discovered.CustomResourceDefinitionNames)
+
 	return nil
 }
 
+// validatingWebhookConfigurationNames returns the names of all ValidatingWebhookConfigurations to patch.
+func (cfg *PatchConfig) validatingWebhookConfigurationNames() []string {
+	if !cfg.PatchValidating {
+		return uniqueNames(cfg.ValidatingWebhookNames)
+	}
+
+	return uniqueNames(cfg.WebhookNames, cfg.ValidatingWebhookNames)
+}
+
+// mutatingWebhookConfigurationNames returns the names of all MutatingWebhookConfigurations to patch.
+func (cfg *PatchConfig) mutatingWebhookConfigurationNames() []string {
+	if !cfg.PatchMutating {
+		return uniqueNames(cfg.MutatingWebhookNames)
+	}
+
+	return uniqueNames(cfg.WebhookNames, cfg.MutatingWebhookNames)
+}
+
+// uniqueNames concatenates the non-empty names of all lists, dropping duplicates while keeping the order.
+func uniqueNames(lists ...[]string) []string {
+	var names []string
+
+	for _, list := range lists {
+		for _, name := range list {
+			if name != "" && !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+
+	return names
+}
+
 func patchCommand(_ *cobra.Command, _ []string) error {
-	client, aggregationClient, err := newKubernetesClients(cfg.kubeconfig)
+	if err := configureSecretProfile(); err != nil {
+		return err
+	}
+
+	client, aggregationClient, apiExtensionsClient, dynamicClient, err := newKubernetesClients(cfg.kubeconfig)
 	if err != nil {
 		return fmt.Errorf("failed to create kubernetes client: %w", err)
 	}
 
-	patcher, err := k8s.New(client, aggregationClient)
+	patcher, err := k8s.New(client, aggregationClient, apiExtensionsClient, dynamicClient)
 	if err != nil {
 		return fmt.Errorf("failed to create patcher: %w", err)
 	}
 
-	config := &PatchConfig{
-		SecretName:         cfg.secretName,
-		CaName:             cfg.caName,
-		Namespace:          cfg.namespace,
-		PatchMutating:      cfg.patchMutating,
-		PatchValidating:    cfg.patchValidating,
-		PatchFailurePolicy: cfg.patchFailurePolicy,
-		APIServiceName:     cfg.apiServiceName,
-		WebhookName:        cfg.webhookName,
-		PatchMethod:        cfg.patchMethod,
-		Patcher:            patcher,
+	if err = configureDryRun(patcher); err != nil {
+		return err
+	}
+
+	if err = detectLegacySecret(context.Background(), patcher); err != nil {
+		return err
 	}
 
-	if err := Patch(context.Background(), config); err != nil {
+	defer configureEvents(patcher, client)()
+
+	if err := Patch(context.Background(), newPatchConfig(patcher)); err != nil {
 		if wrappedErr := errors.Unwrap(err); wrappedErr != nil {
 			err = wrappedErr
 		}
@@ -126,20 +306,75 @@ func patchCommand(_ *cobra.Command, _ []string) error {
 	return nil
 }
 
+// newPatchConfig returns the patch configuration from the command line flags.
+func newPatchConfig(k *k8s.K8s) *PatchConfig {
+	return &PatchConfig{
+		SecretName:             cfg.secretName,
+		Namespace:              cfg.namespace,
+		PatchMutating:          cfg.patchMutating,
+		PatchValidating:        cfg.patchValidating,
+		PatchFailurePolicy:     cfg.patchFailurePolicy,
+		APIServiceNames:        cfg.apiServiceNames,
+		WebhookNames:           cfg.webhookNames,
+		ValidatingWebhookNames: cfg.validatingWebhookNames,
+		MutatingWebhookNames:   cfg.mutatingWebhookNames,
+		CRDNames:               cfg.crdNames,
+		WebhookFilter: k8s.WebhookFilter{
+			Names:            cfg.webhookEntryNames,
+			ServiceName:      cfg.webhookServiceName,
+			ServiceNamespace: cfg.webhookServiceNamespace,
+		},
+		Selector:             cfg.selector,
+		InjectFromAnnotation: cfg.injectFromAnnotation,
+		PatchMethod:          cfg.patchMethod,
+		DryRun:               cfg.dryRun,
+		DiffOutput:           rootCmd.OutOrStdout(),
+		Patcher:              k,
+		Metrics:              certgenMetrics,
+		CAStore:              storage.NewSecret(k, cfg.secretName, cfg.secretType, cfg.namespace, k8s.SecretKeys{CA: cfg.caName}, k8s.SecretMetadata{}),
+	}
+}
+
 //nolint:lll
 func init() {
 	rootCmd.AddCommand(patch)
 	patch.Flags().StringVar(&cfg.secretName, "secret-name", "", "Name of the secret where certificate information will be read from")
-	patch.Flags().StringVar(&cfg.secretType, "secret-type", "", "Name of the secret where certificate information will be read from")
+	patch.Flags().StringVar(&cfg.secretType, "secret-type", "", "Type of the secret where certificate information will be read from. Defaults to the type of --secret-profile")
 	patch.Flags().StringVar(&cfg.namespace, "namespace", "", "Namespace of the secret where certificate information will be read from")
-	patch.Flags().StringVar(&cfg.webhookName, "webhook-name", "", "Name of ValidatingWebhookConfiguration and MutatingWebhookConfiguration that will be updated")
-	patch.Flags().StringVar(&cfg.apiServiceName, "apiservice-name", "", "Name of APIService that will be patched")
-	patch.Flags().StringVar(&cfg.caName, "ca-name", "ca", "Name of cert file in the secret")
-	patch.Flags().StringVar(&cfg.patchMethod, "patch-mode", "update", "Patch method to use: patch|update. patch uses server side apply, update uses a full object update")
-	patch.Flags().BoolVar(&cfg.patchValidating, "patch-validating", true, "If true, patch ValidatingWebhookConfiguration")
-	patch.Flags().BoolVar(&cfg.patchMutating, "patch-mutating", true, "If true, patch MutatingWebhookConfiguration")
-	patch.Flags().StringVar(&cfg.patchFailurePolicy, "patch-failure-policy", "", "If set, patch the webhooks with this failure policy. Valid options are Ignore or Fail")
+	patch.Flags().StringVar(&cfg.caName, "ca-name", "", "Name of ca file in the secret. Defaults to the ca key name of --secret-profile, falling back to 'ca' if the secret has no such key")
+	addSecretProfileFlag(patch.Flags())
+	addPatchFlags(patch.Flags())
+	addDryRunFlag(patch.Flags())
+	addEventsFlag(patch.Flags())
+	addPushgatewayFlags(patch.Flags())
 
 	_ = patch.MarkFlagRequired("secret-name")
 	_ = patch.MarkFlagRequired("namespace")
 }
+
+// addPatchFlags adds the flags selecting the objects to patch and how to patch them.
+//
+//nolint:lll
+func addPatchFlags(flags *pflag.FlagSet) {
+	addObjectFlags(flags)
+	flags.StringVar(&cfg.patchMethod, "patch-mode", "update", "Patch method to use: patch|update. patch uses server side apply, update uses a full object update")
+	flags.StringVar(&cfg.patchFailurePolicy, "patch-failure-policy", "", "If set, patch the webhooks with this failure policy. Valid options are Ignore or Fail")
+}
+
+// addObjectFlags adds the flags selecting the objects that carry the ca.
+//
+//nolint:lll
+func addObjectFlags(flags *pflag.FlagSet) {
+	flags.StringSliceVar(&cfg.webhookNames, "webhook-name", nil, "Name of ValidatingWebhookConfiguration and MutatingWebhookConfiguration that will be updated. May be repeated")
+	flags.StringSliceVar(&cfg.validatingWebhookNames, "validating-webhook-name", nil, "Name of a ValidatingWebhookConfiguration that will be updated, regardless of --patch-validating. May be repeated")
+	flags.StringSliceVar(&cfg.mutatingWebhookNames, "mutating-webhook-name", nil, "Name of a MutatingWebhookConfiguration that will be updated, regardless of --patch-mutating. May be repeated")
+	flags.StringSliceVar(&cfg.apiServiceNames, "apiservice-name", nil, "Name of APIService that will be patched. May be repeated")
+	flags.StringSliceVar(&cfg.webhookEntryNames, "webhook-entry-name", nil, "Glob of the webhook entries within the webhook configurations that will be patched, e.g. '*.example.com'. May be repeated. Defaults to all entries")
+	flags.StringVar(&cfg.webhookServiceName, "webhook-service-name", "", "If set, only patch webhook entries whose clientConfig.service has this name")
+	flags.StringVar(&cfg.webhookServiceNamespace, "webhook-service-namespace", "", "If set, only patch webhook entries whose clientConfig.service is in this namespace")
+	flags.StringVar(&cfg.selector, "selector", "", "Label selector of ValidatingWebhookConfigurations, MutatingWebhookConfigurations, APIServices and CustomResourceDefinitions that will be patched")
+	flags.BoolVar(&cfg.injectFromAnnotation, "inject-from-annotation", false, "If true, patch all ValidatingWebhookConfigurations, MutatingWebhookConfigurations, APIServices and CustomResourceDefinitions annotated with "+k8s.InjectFromAnnotation+"=<namespace>/<secret-name>")
+	flags.StringSliceVar(&cfg.crdNames, "crd-name", nil, "Name of a CustomResourceDefinition whose conversion webhook will be patched. May be repeated")
+	flags.BoolVar(&cfg.patchValidating, "patch-validating", true, "If true, patch ValidatingWebhookConfiguration")
+	flags.BoolVar(&cfg.patchMutating, "patch-mutating", true, "If true, patch MutatingWebhookConfiguration")
+}
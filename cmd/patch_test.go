@@ -1,13 +1,17 @@
 package cmd_test
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/jkroepke/kube-webhook-certgen/cmd"
 	"github.com/jkroepke/kube-webhook-certgen/pkg/k8s"
+	"github.com/jkroepke/kube-webhook-certgen/pkg/metrics"
 )
 
 func Test_Patch(t *testing.T) {
@@ -19,12 +23,39 @@ func Test_Patch(t *testing.T) {
 		t.Parallel()
 
 		config := testPatchConfig()
-		config.APIServiceName = "bar"
+		config.APIServiceNames = []string{"bar", "baz"}
 
 		patcher := testPatcher()
 		patcher.patchObjects = func(_ context.Context, options k8s.PatchOptions) error {
-			if options.APIServiceName != config.APIServiceName {
-				return fmt.Errorf("unexpected APIService name %q, expected %q", options.APIServiceName, config.APIServiceName)
+			if !reflect.DeepEqual(options.APIServiceNames, config.APIServiceNames) {
+				return fmt.Errorf("unexpected APIService names %v, expected %v", options.APIServiceNames, config.APIServiceNames)
+			}
+
+			return nil
+		}
+		config.Patcher = patcher
+
+		if err := cmd.Patch(ctx, config); err != nil {
+			t.Fatalf("Unexpected patching error: %v", err)
+		}
+	})
+
+	t.Run("patches_only_crds_when_requested", func(t *testing.T) {
+		t.Parallel()
+
+		config := testPatchConfig()
+		config.PatchValidating = false
+		config.PatchMutating = false
+		config.CRDNames = []string{"foos.example.com", "bars.example.com"}
+
+		patcher := testPatcher()
+		patcher.patchObjects = func(_ context.Context, options k8s.PatchOptions) error {
+			if !reflect.DeepEqual(options.CustomResourceDefinitionNames, config.CRDNames) {
+				return fmt.Errorf("unexpected CustomResourceDefinition names %v, expected %v", options.CustomResourceDefinitionNames, config.CRDNames)
+			}
+
+			if len(options.ValidatingWebhookConfigurationNames) != 0 || len(options.MutatingWebhookConfigurationNames) != 0 {
+				t.Error("expected webhooks to not be patched")
 			}
 
 			return nil
@@ -40,12 +71,12 @@ func Test_Patch(t *testing.T) {
 		t.Parallel()
 
 		config := testPatchConfig()
-		config.WebhookName = "foo"
+		config.WebhookNames = []string{"foo", "bar"}
 
 		patcher := testPatcher()
 		patcher.patchObjects = func(_ context.Context, options k8s.PatchOptions) error {
-			if options.ValidatingWebhookConfigurationName != config.WebhookName {
-				return fmt.Errorf("unexpected object name %q, expected %q", options.ValidatingWebhookConfigurationName, config.WebhookName)
+			if !reflect.DeepEqual(options.ValidatingWebhookConfigurationNames, config.WebhookNames) {
+				return fmt.Errorf("unexpected object names %v, expected %v", options.ValidatingWebhookConfigurationNames, config.WebhookNames)
 			}
 
 			return nil
@@ -66,15 +97,15 @@ func Test_Patch(t *testing.T) {
 
 		patcher := testPatcher()
 		patcher.patchObjects = func(_ context.Context, options k8s.PatchOptions) error {
-			if options.ValidatingWebhookConfigurationName == "" {
+			if len(options.ValidatingWebhookConfigurationNames) == 0 {
 				t.Error("expected validating webhook to be patched")
 			}
 
-			if options.MutatingWebhookConfigurationName != "" {
+			if len(options.MutatingWebhookConfigurationNames) != 0 {
 				t.Error("expected mutating webhook to not be patched")
 			}
 
-			if options.APIServiceName != "" {
+			if len(options.APIServiceNames) != 0 {
 				t.Error("expected APIService to not be patched")
 			}
 
@@ -96,15 +127,15 @@ func Test_Patch(t *testing.T) {
 
 		patcher := testPatcher()
 		patcher.patchObjects = func(_ context.Context, options k8s.PatchOptions) error {
-			if options.ValidatingWebhookConfigurationName == "" {
+			if len(options.ValidatingWebhookConfigurationNames) == 0 {
 				t.Error("expected validating webhook to be patched")
 			}
 
-			if options.MutatingWebhookConfigurationName == "" {
+			if len(options.MutatingWebhookConfigurationNames) == 0 {
 				t.Error("expected mutating webhook to be patched")
 			}
 
-			if options.APIServiceName != "" {
+			if len(options.APIServiceNames) != 0 {
 				t.Error("expected APIService to not be patched")
 			}
 
@@ -117,6 +148,107 @@ func Test_Patch(t *testing.T) {
 		}
 	})
 
+	t.Run("patches_distinct_validating_and_mutating_webhooks", func(t *testing.T) {
+		t.Parallel()
+
+		config := testPatchConfig()
+		config.PatchValidating = true
+		config.PatchMutating = false
+		config.WebhookNames = []string{"shared"}
+		config.ValidatingWebhookNames = []string{"validating", "shared"}
+		config.MutatingWebhookNames = []string{"mutating"}
+
+		patcher := testPatcher()
+		patcher.patchObjects = func(_ context.Context, options k8s.PatchOptions) error {
+			if expected := []string{"shared", "validating"}; !reflect.DeepEqual(options.ValidatingWebhookConfigurationNames, expected) {
+				return fmt.Errorf("unexpected validating webhook names %v, expected %v", options.ValidatingWebhookConfigurationNames, expected)
+			}
+
+			if expected := []string{"mutating"}; !reflect.DeepEqual(options.MutatingWebhookConfigurationNames, expected) {
+				return fmt.Errorf("unexpected mutating webhook names %v, expected %v", options.MutatingWebhookConfigurationNames, expected)
+			}
+
+			return nil
+		}
+		config.Patcher = patcher
+
+		if err := cmd.Patch(ctx, config); err != nil {
+			t.Fatalf("Unexpected patching error: %v", err)
+		}
+	})
+
+	t.Run("patches_discovered_objects", func(t *testing.T) {
+		t.Parallel()
+
+		config := testPatchConfig()
+		config.PatchValidating = false
+		config.PatchMutating = true
+		config.WebhookNames = nil
+		config.Selector = "app=foo"
+		config.InjectFromAnnotation = true
+		config.Namespace = "ns"
+		config.SecretName = "secret"
+
+		patcher := testPatcher()
+		patcher.discoverObjects = func(_ context.Context, options k8s.DiscoveryOptions) (k8s.PatchOptions, error) {
+			if options.LabelSelector != "app=foo" || options.InjectFrom != "ns/secret" {
+				return k8s.PatchOptions{}, fmt.Errorf("unexpected discovery options %+v", options)
+			}
+
+			return k8s.PatchOptions{
+				ValidatingWebhookConfigurationNames: []string{"validating"},
+				MutatingWebhookConfigurationNames:   []string{"mutating"},
+				APIServiceNames:                     []string{"v1.foo"},
+				CustomResourceDefinitionNames:       []string{"foos.example.com"},
+			}, nil
+		}
+		patcher.patchObjects = func(_ context.Context, options k8s.PatchOptions) error {
+			if len(options.ValidatingWebhookConfigurationNames) != 0 {
+				t.Error("expected discovered validating webhook to not be patched")
+			}
+
+			if !reflect.DeepEqual(options.MutatingWebhookConfigurationNames, []string{"mutating"}) {
+				t.Errorf("unexpected mutating webhook names %v", options.MutatingWebhookConfigurationNames)
+			}
+
+			if !reflect.DeepEqual(options.APIServiceNames, []string{"v1.foo"}) {
+				t.Errorf("unexpected APIService names %v", options.APIServiceNames)
+			}
+
+			if !reflect.DeepEqual(options.CustomResourceDefinitionNames, []string{"foos.example.com"}) {
+				t.Errorf("unexpected CustomResourceDefinition names %v", options.CustomResourceDefinitionNames)
+			}
+
+			return nil
+		}
+		config.Patcher = patcher
+
+		if err := cmd.Patch(ctx, config); err != nil {
+			t.Fatalf("Unexpected patching error: %v", err)
+		}
+	})
+
+	t.Run("passes_webhook_filter", func(t *testing.T) {
+		t.Parallel()
+
+		config := testPatchConfig()
+		config.WebhookFilter = k8s.WebhookFilter{Names: []string{"*.example.com"}, ServiceName: "webhook", ServiceNamespace: "ns"}
+
+		patcher := testPatcher()
+		patcher.patchObjects = func(_ context.Context, options k8s.PatchOptions) error {
+			if !reflect.DeepEqual(options.WebhookFilter, config.WebhookFilter) {
+				return fmt.Errorf("unexpected webhook filter %+v, expected %+v", options.WebhookFilter, config.WebhookFilter)
+			}
+
+			return nil
+		}
+		config.Patcher = patcher
+
+		if err := cmd.Patch(ctx, config); err != nil {
+			t.Fatalf("Unexpected patching error: %v", err)
+		}
+	})
+
 	t.Run("use_empty_policy_when_ignore_is_requested", func(t *testing.T) {
 		t.Parallel()
 
@@ -174,14 +306,141 @@ func Test_Patch(t *testing.T) {
 
 			return nil
 		}
-		patcher.getCaFromSecret = func(context.Context, string, string, string) ([]byte, error) {
+		config.CAStore = &store{loadCA: func(context.Context) ([]byte, error) {
 			return expectedCA, nil
+		}}
+		config.Patcher = patcher
+
+		if err := cmd.Patch(ctx, config); err != nil {
+			t.Fatalf("Unexpected patching error: %v", err)
+		}
+	})
+
+	t.Run("use_given_ca_bundle_without_reading_secret", func(t *testing.T) {
+		t.Parallel()
+
+		expectedCA := []byte("foo")
+
+		config := testPatchConfig()
+		config.CABundle = expectedCA
+
+		patcher := testPatcher()
+		patcher.patchObjects = func(_ context.Context, options k8s.PatchOptions) error {
+			if !reflect.DeepEqual(options.CABundle, expectedCA) {
+				return fmt.Errorf("unexpected CA, expected %q, got %q", string(expectedCA), string(options.CABundle))
+			}
+
+			return nil
+		}
+		config.CAStore = &store{loadCA: func(context.Context) ([]byte, error) {
+			return nil, errors.New("secret must not be read")
+		}}
+		config.Patcher = patcher
+
+		if err := cmd.Patch(ctx, config); err != nil {
+			t.Fatalf("Unexpected patching error: %v", err)
+		}
+	})
+
+	t.Run("prints_diff_without_patching_on_client_dry_run", func(t *testing.T) {
+		t.Parallel()
+
+		var output bytes.Buffer
+
+		config := testPatchConfig()
+		config.DryRun = "client"
+		config.DiffOutput = &output
+
+		patcher := testPatcher()
+		patcher.patchObjects = func(context.Context, k8s.PatchOptions) error {
+			return errors.New("objects must not be patched")
+		}
+		patcher.diffObjects = func(context.Context, k8s.PatchOptions) ([]k8s.ObjectDiff, error) {
+			return []k8s.ObjectDiff{{
+				Kind:    "ValidatingWebhookConfiguration",
+				Name:    "foo",
+				Changes: []k8s.Change{{Field: "webhooks[foo].failurePolicy", Old: "Ignore", New: "Fail"}},
+			}}, nil
 		}
 		config.Patcher = patcher
 
 		if err := cmd.Patch(ctx, config); err != nil {
 			t.Fatalf("Unexpected patching error: %v", err)
 		}
+
+		expected := "ValidatingWebhookConfiguration foo:\n  webhooks[foo].failurePolicy: Ignore -> Fail\n"
+		if output.String() != expected {
+			t.Fatalf("unexpected diff output %q, expected %q", output.String(), expected)
+		}
+	})
+
+	t.Run("prints_diff_and_patches_on_server_dry_run", func(t *testing.T) {
+		t.Parallel()
+
+		var output bytes.Buffer
+
+		config := testPatchConfig()
+		config.DryRun = "server"
+		config.DiffOutput = &output
+
+		patched := false
+		patcher := testPatcher()
+		patcher.patchObjects = func(context.Context, k8s.PatchOptions) error {
+			patched = true
+
+			return nil
+		}
+		patcher.diffObjects = func(context.Context, k8s.PatchOptions) ([]k8s.ObjectDiff, error) {
+			return []k8s.ObjectDiff{{Kind: "ValidatingWebhookConfiguration", Name: "foo"}}, nil
+		}
+		config.Patcher = patcher
+
+		if err := cmd.Patch(ctx, config); err != nil {
+			t.Fatalf("Unexpected patching error: %v", err)
+		}
+
+		if !patched {
+			t.Error("expected objects to be patched with server dry run")
+		}
+
+		if output.String() != "ValidatingWebhookConfiguration foo: no changes\n" {
+			t.Errorf("unexpected diff output %q", output.String())
+		}
+	})
+
+	t.Run("reports_objects_which_failed_to_patch", func(t *testing.T) {
+		t.Parallel()
+
+		config := testPatchConfig()
+		config.WebhookNames = []string{"foo", "bar"}
+		config.Metrics = metrics.New()
+
+		patcher := testPatcher()
+		patcher.failures = map[string]error{"bar": errors.New("conflict")}
+		config.Patcher = patcher
+
+		err := cmd.Patch(ctx, config)
+		if err == nil {
+			t.Fatal("Expected error while patching")
+		}
+
+		if !strings.Contains(err.Error(), "ValidatingWebhookConfiguration 'bar': conflict") || strings.Contains(err.Error(), "'foo'") {
+			t.Errorf("expected error to name only the failed object, got %v", err)
+		}
+
+		var b strings.Builder
+		if _, err = config.Metrics.WriteTo(&b); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, line := range []string{
+			"kube_webhook_certgen_patched_objects_total 1\n",
+			"kube_webhook_certgen_patch_failures_total{kind=\"ValidatingWebhookConfiguration\"} 1\n",
+		} {
+			if !strings.Contains(b.String(), line) {
+				t.Errorf("expected metrics to contain %q, got:\n%s", line, b.String())
+			}
+		}
 	})
 
 	t.Run("returns_error_when", func(t *testing.T) {
@@ -194,17 +453,23 @@ func Test_Patch(t *testing.T) {
 			"no_webhooks_are_requested_for_patching": func(c *cmd.PatchConfig) {
 				c.PatchValidating = false
 				c.PatchMutating = false
-				c.APIServiceName = ""
+				c.APIServiceNames = nil
 			},
 			"unsupported_patch_failure_policy_is_defined": func(c *cmd.PatchConfig) {
 				c.PatchFailurePolicy = "foo"
 			},
 			"ca_certificate_from_secret_is_empty": func(c *cmd.PatchConfig) {
-				patcher := testPatcher()
-				patcher.getCaFromSecret = func(_ context.Context, _, _, _ string) ([]byte, error) {
+				c.CAStore = &store{loadCA: func(context.Context) ([]byte, error) {
 					return nil, nil
-				}
-				c.Patcher = patcher
+				}}
+			},
+			"ca_certificate_from_secret_is_an_empty_key": func(c *cmd.PatchConfig) {
+				c.CAStore = &store{loadCA: func(context.Context) ([]byte, error) {
+					return []byte{}, nil
+				}}
+			},
+			"ca_certificate_cannot_be_loaded": func(c *cmd.PatchConfig) {
+				c.CAStore = &store{}
 			},
 		} {
 			t.Run(name, func(t *testing.T) {
@@ -223,15 +488,40 @@ func Test_Patch(t *testing.T) {
 
 type patcher struct {
 	patchObjects    func(context.Context, k8s.PatchOptions) error
-	getCaFromSecret func(context.Context, string, string, string) ([]byte, error)
+	discoverObjects func(context.Context, k8s.DiscoveryOptions) (k8s.PatchOptions, error)
+	diffObjects     func(context.Context, k8s.PatchOptions) ([]k8s.ObjectDiff, error)
+	// failures are the errors of the objects which fail to patch, by name. All other objects are patched.
+	failures map[string]error
 }
 
-func (p *patcher) PatchObjects(ctx context.Context, options k8s.PatchOptions) error {
-	return p.patchObjects(ctx, options)
+// PatchObjects reports the outcome of each object like k8s.K8s.PatchObjects, unless patchObjects fails.
+func (p *patcher) PatchObjects(ctx context.Context, options k8s.PatchOptions) (k8s.PatchResult, error) {
+	if err := p.patchObjects(ctx, options); err != nil {
+		return nil, err
+	}
+
+	var result k8s.PatchResult
+
+	for kind, names := range map[string][]string{
+		"APIService":                     options.APIServiceNames,
+		"CustomResourceDefinition":       options.CustomResourceDefinitionNames,
+		"ValidatingWebhookConfiguration": options.ValidatingWebhookConfigurationNames,
+		"MutatingWebhookConfiguration":   options.MutatingWebhookConfigurationNames,
+	} {
+		for _, name := range names {
+			result = append(result, k8s.ObjectResult{Kind: kind, Name: name, Err: p.failures[name]})
+		}
+	}
+
+	return result, result.Err()
+}
+
+func (p *patcher) DiscoverObjects(ctx context.Context, options k8s.DiscoveryOptions) (k8s.PatchOptions, error) {
+	return p.discoverObjects(ctx, options)
 }
 
-func (p *patcher) GetCaFromSecret(ctx context.Context, caName, secretName, namespace string) ([]byte, error) {
-	return p.getCaFromSecret(ctx, caName, secretName, namespace)
+func (p *patcher) DiffObjects(ctx context.Context, options k8s.PatchOptions) ([]k8s.ObjectDiff, error) {
+	return p.diffObjects(ctx, options)
 }
 
 func testPatcher() *patcher {
@@ -239,14 +529,22 @@ func testPatcher() *patcher {
 		patchObjects: func(context.Context, k8s.PatchOptions) error {
 			return nil
 		},
-		getCaFromSecret: func(context.Context, string, string, string) ([]byte, error) { return make([]byte, 0), nil },
+		discoverObjects: func(context.Context, k8s.DiscoveryOptions) (k8s.PatchOptions, error) {
+			return k8s.PatchOptions{}, nil
+		},
+		diffObjects: func(context.Context, k8s.PatchOptions) ([]k8s.ObjectDiff, error) {
+			return nil, nil
+		},
 	}
 }
 
 func testPatchConfig() *cmd.PatchConfig {
 	return &cmd.PatchConfig{
 		PatchValidating: true,
-		WebhookName:     "foo",
+		WebhookNames:    []string{"foo"},
 		Patcher:         testPatcher(),
+		CAStore: &store{loadCA: func(context.Context) ([]byte, error) {
+			return []byte("ca"), nil
+		}},
 	}
 }
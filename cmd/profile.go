@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/jkroepke/kube-webhook-certgen/pkg/k8s"
+	"github.com/spf13/pflag"
+)
+
+// addSecretProfileFlag adds the flag selecting the type and the key names of the secret.
+//
+//nolint:lll
+func addSecretProfileFlag(flags *pflag.FlagSet) {
+	flags.StringVar(&cfg.secretProfile, "secret-profile", "", "Type and key names of the secret: tls (kubernetes.io/tls with ca.crt, tls.crt, tls.key and the ca key in ca.key), opaque-legacy (Opaque with ca, cert and key, without the ca key) or custom (--secret-type, --ca-name, --cert-name and --key-name, defaulting to tls). Defaults to tls, or to opaque-legacy for an existing secret created by an older release")
+}
+
+// configureSecretProfile resolves --secret-profile and replaces the secret type and key names in cfg with the resolved layout.
+// If --secret-profile is not set, the layout of tls is used until detectLegacySecret finds a secret of an older release.
+func configureSecretProfile() error {
+	cfg.secretLayoutFlags = k8s.SecretLayout{
+		Type: cfg.secretType,
+		Keys: k8s.SecretKeys{CA: cfg.caName, Cert: cfg.certName, Key: cfg.keyName, CAKey: cfg.caKeyName},
+	}
+
+	profile := k8s.SecretProfile(cfg.secretProfile)
+	if profile == "" {
+		profile = k8s.SecretProfileTLS
+	}
+
+	layout, err := profile.Layout(cfg.secretLayoutFlags)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	applySecretLayout(layout)
+
+	return nil
+}
+
+// detectLegacySecret switches to the layout of opaque-legacy if --secret-profile is not set and the secret was created
+// by an older release, so existing installations keep working. It fails with a migration hint if tls is set explicitly.
+func detectLegacySecret(ctx context.Context, k *k8s.K8s) error {
+	profile := k8s.SecretProfile(cfg.secretProfile)
+	if (profile != "" && profile != k8s.SecretProfileTLS) || cfg.secretName == "" {
+		return nil
+	}
+
+	legacy, err := k.IsLegacySecret(ctx, cfg.secretName, cfg.namespace)
+	if err != nil {
+		return fmt.Errorf("failed to read secret '%s' in namespace '%s': %w", cfg.secretName, cfg.namespace, err)
+	}
+
+	if !legacy {
+		return nil
+	}
+
+	layout, err := k8s.SecretProfileOpaqueLegacy.Layout(cfg.secretLayoutFlags)
+	if profile == k8s.SecretProfileTLS || err != nil {
+		return fmt.Errorf("secret '%s' in namespace '%s' was created by an older release and stores the certificates in an Opaque secret "+
+			"under ca, cert and key. Pass --secret-profile=opaque-legacy to keep using it, or delete the secret to have it recreated "+
+			"with --secret-profile=tls", cfg.secretName, cfg.namespace)
+	}
+
+	slog.InfoContext(ctx, "secret was created by an older release, using secret profile opaque-legacy. "+
+		"Pass --secret-profile=opaque-legacy to keep it, or delete the secret to migrate to the tls profile",
+		slog.String("secret", cfg.secretName),
+		slog.String("namespace", cfg.namespace),
+	)
+
+	applySecretLayout(layout)
+
+	return nil
+}
+
+// applySecretLayout replaces the secret type and key names in cfg with layout.
+func applySecretLayout(layout k8s.SecretLayout) {
+	cfg.secretType = layout.Type
+	cfg.caName = layout.Keys.CA
+	cfg.certName = layout.Keys.Cert
+	cfg.keyName = layout.Keys.Key
+	cfg.caKeyName = layout.Keys.CAKey
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jkroepke/kube-webhook-certgen/pkg/certs"
+	"github.com/jkroepke/kube-webhook-certgen/pkg/k8s"
+)
+
+// startRollover generates a new ca and adds it in front of the existing ca. The certificate is kept,
+// so clients trust both cas before the certificate is issued by the new one.
+func (i *issuer) startRollover(existing *k8s.Certificates) (*k8s.Certificates, error) {
+	ca, err := certs.GenerateCA(i.options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ca: %w", err)
+	}
+
+	slog.Info("ca expires soon, starting ca rollover",
+		slog.String("phase", string(k8s.RolloverPhaseCABundled)),
+		slog.Duration("grace_period", i.rolloverGracePeriod),
+	)
+
+	return &k8s.Certificates{
+		CA:       append(append([]byte{}, ca.CertPEM...), existing.CA...),
+		Cert:     existing.Cert,
+		Key:      existing.Key,
+		CAKey:    ca.KeyPEM,
+		Rollover: &k8s.Rollover{Phase: k8s.RolloverPhaseCABundled, Since: time.Now(), GracePeriod: i.rolloverGracePeriod},
+	}, nil
+}
+
+// continueRollover moves a ca rollover to its next phase once the grace period of the current phase has passed.
+// The grace period is the larger of the one the rollover was started with and --ca-rollover-grace-period, so a
+// rollover left over from an earlier run is not rushed. A certificate not matching hosts is reissued with the new ca
+// in any phase. It returns nil if the existing certificates are kept.
+func (i *issuer) continueRollover(existing *k8s.Certificates, bundle *certs.Bundle, hosts string) (*k8s.Certificates, error) {
+	// The new ca leads the ca and its key is stored as ca key.
+	block, _ := pem.Decode(existing.CA)
+	if block == nil {
+		return nil, errors.New("failed to load new ca of ca rollover: no PEM encoded certificate found")
+	}
+
+	ca, err := certs.LoadCA(pem.EncodeToMemory(block), existing.CAKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load new ca of ca rollover: %w", err)
+	}
+
+	gracePeriod := max(existing.Rollover.GracePeriod, i.rolloverGracePeriod)
+	if gracePeriod == 0 {
+		return nil, fmt.Errorf("ca rollover is in phase %s, but its grace period is unknown. Set ca-rollover-grace-period to continue it",
+			existing.Rollover.Phase)
+	}
+
+	next := &k8s.Certificates{
+		CA:       existing.CA,
+		Cert:     existing.Cert,
+		Key:      existing.Key,
+		CAKey:    existing.CAKey,
+		Rollover: existing.Rollover,
+	}
+	reissue := !bundle.MatchesHosts(hosts)
+
+	switch remaining := gracePeriod - time.Since(existing.Rollover.Since); {
+	case remaining > 0 && reissue:
+		slog.Info("certificate does not match requested hosts, reissuing certificate with the new ca",
+			slog.String("phase", string(existing.Rollover.Phase)),
+			slog.String("hosts", hosts),
+		)
+	case remaining > 0:
+		slog.Info("waiting for the grace period of the ca rollover phase",
+			slog.String("phase", string(existing.Rollover.Phase)),
+			slog.Duration("remaining", remaining),
+		)
+
+		return nil, nil //nolint:nilnil
+	case existing.Rollover.Phase == k8s.RolloverPhaseCABundled:
+		slog.Info("issuing certificate with the new ca",
+			slog.String("phase", string(k8s.RolloverPhaseLeafRotated)),
+		)
+
+		reissue = true
+		next.Rollover = &k8s.Rollover{Phase: k8s.RolloverPhaseLeafRotated, Since: time.Now(), GracePeriod: gracePeriod}
+	default:
+		slog.Info("dropping the old ca, ca rollover completed",
+			slog.String("phase", string(k8s.RolloverPhaseCompleted)),
+		)
+
+		next.CA = ca.CertPEM
+		next.Rollover = &k8s.Rollover{Phase: k8s.RolloverPhaseCompleted, Since: time.Now(), GracePeriod: gracePeriod}
+	}
+
+	if reissue {
+		if next.Cert, next.Key, err = ca.SignLeaf(hosts, i.options); err != nil {
+			return nil, fmt.Errorf("failed to generate certs: %w", err)
+		}
+	}
+
+	return next, nil
+}
+
+// validateCARollover validates --ca-rollover-grace-period.
+func validateCARollover() error {
+	if cfg.caRolloverGracePeriod == 0 {
+		return nil
+	}
+
+	switch {
+	case cfg.caRolloverGracePeriod < 0:
+		return fmt.Errorf("ca-rollover-grace-period %s must not be negative", cfg.caRolloverGracePeriod)
+	case cfg.secretName == "":
+		return errors.New("ca-rollover-grace-period requires secret-name to keep the state of the rollover")
+	case cfg.caKeyName == "":
+		return errors.New("ca-rollover-grace-period requires ca-key-name to keep the new ca key")
+	case cfg.caSecretName != "" || cfg.caCertFile != "":
+		return errors.New("ca-rollover-grace-period only applies to generated cas, not to ca-secret-name or ca-cert-file")
+	case 2*cfg.caRolloverGracePeriod >= cfg.renewBefore:
+		return fmt.Errorf("ca-rollover-grace-period %s must be less than half of renew-before %s", cfg.caRolloverGracePeriod, cfg.renewBefore)
+	}
+
+	return nil
+}
@@ -4,8 +4,12 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"time"
 
+	"github.com/jkroepke/kube-webhook-certgen/pkg/k8s"
 	"github.com/spf13/cobra"
+	apiextensionsclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/kube-aggregator/pkg/client/clientset_generated/clientset"
@@ -22,22 +26,71 @@ var (
 	}
 
 	cfg = struct {
-		host               string
-		logfmt             string
-		secretName         string
-		secretType         string
-		namespace          string
-		certName           string
-		keyName            string
-		caName             string
-		logLevel           string
-		apiServiceName     string
-		webhookName        string
-		patchFailurePolicy string
-		kubeconfig         string
-		patchMethod        string
-		patchValidating    bool
-		patchMutating      bool
+		host                        string
+		logfmt                      string
+		secretName                  string
+		secretType                  string
+		secretProfile               string
+		caBundleAction              string
+		secretOwnerAPIVersion       string
+		secretOwnerKind             string
+		secretOwnerName             string
+		namespace                   string
+		certName                    string
+		keyName                     string
+		caName                      string
+		caKeyName                   string
+		caSecretName                string
+		caSecretNamespace           string
+		caSecretCertName            string
+		caSecretKeyName             string
+		caCertFile                  string
+		caKeyFile                   string
+		logLevel                    string
+		patchFailurePolicy          string
+		kubeconfig                  string
+		patchMethod                 string
+		keyType                     string
+		selector                    string
+		dryRun                      string
+		output                      string
+		outputDir                   string
+		certFileMode                string
+		keyFileMode                 string
+		webhookServiceName          string
+		webhookServiceNamespace     string
+		apiServiceNames             []string
+		webhookNames                []string
+		validatingWebhookNames      []string
+		mutatingWebhookNames        []string
+		crdNames                    []string
+		webhookEntryNames           []string
+		caConfigMapName             string
+		caConfigMapKeys             []string
+		caConfigMapNamespaces       []string
+		secretLabels                map[string]string
+		secretAnnotations           map[string]string
+		secretLayoutFlags           k8s.SecretLayout
+		leaderElectionLeaseName     string
+		leaderElectionNamespace     string
+		metricsAddress              string
+		pushgatewayURL              string
+		pushgatewayJob              string
+		caLifetime                  time.Duration
+		certLifetime                time.Duration
+		clockSkew                   time.Duration
+		renewBefore                 time.Duration
+		caRolloverGracePeriod       time.Duration
+		resyncPeriod                time.Duration
+		leaderElectionLeaseDuration time.Duration
+		leaderElectionRenewDeadline time.Duration
+		leaderElectionRetryPeriod   time.Duration
+		leaderElect                 bool
+		recordEvents                bool
+		injectFromAnnotation        bool
+		stdout                      bool
+		patchValidating             bool
+		patchMutating               bool
 	}{}
 )
 
@@ -63,23 +116,33 @@ func rootCommand(cmd *cobra.Command, _ []string) {
 	os.Exit(0) //nolint:revive // exit called intentionally
 }
 
-func newKubernetesClients(kubeconfig string) (kubernetes.Interface, clientset.Interface, error) {
+func newKubernetesClients(kubeconfig string) (kubernetes.Interface, clientset.Interface, apiextensionsclientset.Interface, dynamic.Interface, error) {
 	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
 	if err != nil {
-		return nil, nil, fmt.Errorf("error building kubernetes config: %w", err)
+		return nil, nil, nil, nil, fmt.Errorf("error building kubernetes config: %w", err)
 	}
 
 	c, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		return nil, nil, fmt.Errorf("error creating kubernetes client: %w", err)
+		return nil, nil, nil, nil, fmt.Errorf("error creating kubernetes client: %w", err)
 	}
 
 	aggregatorClientSet, err := clientset.NewForConfig(config)
 	if err != nil {
-		return nil, nil, fmt.Errorf("error creating kubernetes aggregator client: %w", err)
+		return nil, nil, nil, nil, fmt.Errorf("error creating kubernetes aggregator client: %w", err)
 	}
 
-	return c, aggregatorClientSet, nil
+	apiExtensionsClientSet, err := apiextensionsclientset.NewForConfig(config)
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("error creating kubernetes apiextensions client: %w", err)
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("error creating kubernetes dynamic client: %w", err)
+	}
+
+	return c, aggregatorClientSet, apiExtensionsClientSet, dynamicClient, nil
 }
 
 func configureLogging(_ *cobra.Command, _ []string) error {
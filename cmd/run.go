@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/jkroepke/kube-webhook-certgen/pkg/k8s"
+	"github.com/spf13/cobra"
+)
+
+var run = &cobra.Command{
+	Use:     "run",
+	Short:   "Generate or load the certificates in secret 'secret-name' in 'namespace' and patch the ca into the configured objects",
+	Long:    "Combines create and patch: generate a ca and server cert+key if required, store them in secret 'secret-name' in 'namespace' and patch the ca into a ValidatingWebhookConfiguration, MutatingWebhookConfiguration, APIService or CustomResourceDefinition",
+	PreRunE: validateCreateFlags,
+	RunE:    withPushedMetrics(runCommand),
+}
+
+func runCommand(_ *cobra.Command, _ []string) error {
+	if err := configureSecretProfile(); err != nil {
+		return err
+	}
+
+	certOptions, err := newCertOptions()
+	if err != nil {
+		return err
+	}
+
+	clientSet, aggregatorClientSet, apiExtensionsClientSet, dynamicClient, err := newKubernetesClients(cfg.kubeconfig)
+	if err != nil {
+		return fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+
+	k, err := k8s.New(clientSet, aggregatorClientSet, apiExtensionsClientSet, dynamicClient)
+	if err != nil {
+		return fmt.Errorf("failed to create k8s helper: %w", err)
+	}
+
+	if err = configureDryRun(k); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	if err = detectLegacySecret(ctx, k); err != nil {
+		return err
+	}
+
+	defer configureEvents(k, clientSet)()
+
+	certificates, err := createCertificates(ctx, k, certOptions)
+	if err != nil {
+		return err
+	}
+
+	if err = publishCA(ctx, k, certificates); err != nil {
+		return err
+	}
+
+	config := newPatchConfig(k)
+	config.CABundle = certificates.CA
+
+	if err := Patch(ctx, config); err != nil {
+		if wrappedErr := errors.Unwrap(err); wrappedErr != nil {
+			err = wrappedErr
+		}
+
+		return fmt.Errorf("failed to patch webhooks: %w", err)
+	}
+
+	slog.Info("successfully patched webhooks")
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(run)
+	addCreateFlags(run.Flags())
+	addPatchFlags(run.Flags())
+	addDryRunFlag(run.Flags())
+	addEventsFlag(run.Flags())
+	addPushgatewayFlags(run.Flags())
+
+	_ = run.MarkFlagRequired("host")
+	_ = run.MarkFlagRequired("secret-name")
+	_ = run.MarkFlagRequired("namespace")
+
+	run.MarkFlagsRequiredTogether("ca-cert-file", "ca-key-file")
+	run.MarkFlagsRequiredTogether("secret-owner-api-version", "secret-owner-kind", "secret-owner-name")
+	run.MarkFlagsMutuallyExclusive("ca-secret-name", "ca-cert-file")
+}
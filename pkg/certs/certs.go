@@ -1,40 +1,133 @@
 package certs
 
 import (
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"math/big"
 	"net"
+	"slices"
 	"strings"
 	"time"
 )
 
-// GenerateCerts venerates a ca with a leaf certificate and key and returns the ca, cert and key as PEM encoded slices.
-func GenerateCerts(hosts string) ([]byte, []byte, []byte, error) {
-	notBefore := time.Now().Add(time.Minute * -5)
-	notAfter := notBefore.Add(100 * 365 * 24 * time.Hour)
+// KeyType is the algorithm used to generate private keys.
+type KeyType string
 
-	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
+const (
+	KeyTypeECDSAP256 KeyType = "ecdsa-p256"
+	KeyTypeECDSAP384 KeyType = "ecdsa-p384"
+	KeyTypeRSA2048   KeyType = "rsa-2048"
+	KeyTypeRSA3072   KeyType = "rsa-3072"
+	KeyTypeRSA4096   KeyType = "rsa-4096"
+	KeyTypeEd25519   KeyType = "ed25519"
+)
 
-	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
+// Options configures the certificates generated by GenerateCerts.
+type Options struct {
+	// KeyType is the algorithm used for both the ca and the leaf key.
+	KeyType KeyType
+	// CALifetime is the validity of the ca certificate.
+	CALifetime time.Duration
+	// LeafLifetime is the validity of the leaf certificate. It must not exceed CALifetime.
+	LeafLifetime time.Duration
+	// ClockSkew backdates NotBefore of both certificates to tolerate clock differences between nodes.
+	ClockSkew time.Duration
+}
+
+// DefaultOptions returns the options used by kube-webhook-certgen unless configured otherwise.
+func DefaultOptions() Options {
+	return Options{
+		KeyType:      KeyTypeECDSAP256,
+		CALifetime:   100 * 365 * 24 * time.Hour,
+		LeafLifetime: 100 * 365 * 24 * time.Hour,
+		ClockSkew:    5 * time.Minute,
+	}
+}
+
+// Validate checks the options for consistency.
+func (o Options) Validate() error {
+	switch o.KeyType {
+	case KeyTypeECDSAP256, KeyTypeECDSAP384, KeyTypeRSA2048, KeyTypeRSA3072, KeyTypeRSA4096, KeyTypeEd25519:
+	default:
+		return fmt.Errorf("unsupported key type '%s'", o.KeyType)
+	}
+
+	if o.CALifetime <= 0 {
+		return fmt.Errorf("ca lifetime must be positive, got %s", o.CALifetime)
+	}
+
+	if o.LeafLifetime <= 0 {
+		return fmt.Errorf("leaf lifetime must be positive, got %s", o.LeafLifetime)
+	}
+
+	if o.LeafLifetime > o.CALifetime {
+		return fmt.Errorf("leaf lifetime %s must not exceed ca lifetime %s", o.LeafLifetime, o.CALifetime)
+	}
+
+	if o.ClockSkew < 0 {
+		return fmt.Errorf("clock skew must not be negative, got %s", o.ClockSkew)
+	}
+
+	return nil
+}
+
+// CA is a certificate authority that signs leaf certificates.
+type CA struct {
+	Cert *x509.Certificate
+	Key  crypto.Signer
+	// CertPEM is the PEM encoded ca certificate, as distributed in caBundle fields.
+	CertPEM []byte
+	// KeyPEM is the PEM encoded ca key.
+	KeyPEM []byte
+}
+
+// GenerateCerts generates a ca with a leaf certificate and key and returns the ca, cert and key as PEM encoded slices.
+func GenerateCerts(hosts string, options Options) ([]byte, []byte, []byte, error) {
+	ca, err := GenerateCA(options)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	cert, key, err := ca.SignLeaf(hosts, options)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return ca.CertPEM, cert, key, nil
+}
+
+// GenerateCA generates a new self-signed ca.
+func GenerateCA(options Options) (*CA, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	serialNumber, err := newSerialNumber()
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("failed to generate serial number: %w", err)
+		return nil, err
 	}
 
-	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rootKey, err := generateKey(options.KeyType)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("failed scdsa.GenerateKey: %w", err)
+		return nil, fmt.Errorf("failed to generate ca key: %w", err)
 	}
 
 	rootTemplate := x509.Certificate{
 		SerialNumber:          serialNumber,
-		NotBefore:             notBefore,
-		NotAfter:              notAfter,
+		NotBefore:             now.Add(-options.ClockSkew),
+		NotAfter:              now.Add(options.CALifetime),
 		KeyUsage:              x509.KeyUsageCertSign,
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
 		BasicConstraintsValid: true,
@@ -42,31 +135,91 @@ func GenerateCerts(hosts string) ([]byte, []byte, []byte, error) {
 		Subject:               pkix.Name{Organization: []string{"nil1"}},
 	}
 
-	derBytes, err := x509.CreateCertificate(rand.Reader, &rootTemplate, &rootTemplate, &rootKey.PublicKey, rootKey)
+	derBytes, err := x509.CreateCertificate(rand.Reader, &rootTemplate, &rootTemplate, rootKey.Public(), rootKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed createCertificate for Ca: %w", err)
+	}
+
+	rootCert, err := x509.ParseCertificate(derBytes)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("failed createCertificate for Ca: %w", err)
+		return nil, fmt.Errorf("failed to parse generated ca: %w", err)
 	}
 
-	ca := encodeCert(derBytes)
+	keyPEM, err := encodeKey(rootKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode ca key: %w", err)
+	}
+
+	return &CA{
+		Cert:    rootCert,
+		Key:     rootKey,
+		CertPEM: encodeCert(derBytes),
+		KeyPEM:  keyPEM,
+	}, nil
+}
+
+// LoadCA parses a PEM encoded ca certificate and key.
+// It returns an error if the certificate is not a ca or the key does not belong to it.
+func LoadCA(certPEM, keyPEM []byte) (*CA, error) {
+	cert, err := parseCert(certPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ca: %w", err)
+	}
 
-	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if !cert.IsCA {
+		return nil, errors.New("certificate is not a ca")
+	}
+
+	key, err := parseKey(keyPEM)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("failed createLeafKey for certificate: %w", err)
+		return nil, fmt.Errorf("failed to parse ca key: %w", err)
+	}
+
+	pub, ok := key.Public().(interface{ Equal(x crypto.PublicKey) bool })
+	if !ok || !pub.Equal(cert.PublicKey) {
+		return nil, errors.New("ca key does not match ca certificate")
+	}
+
+	return &CA{
+		Cert:    cert,
+		Key:     key,
+		CertPEM: certPEM,
+		KeyPEM:  keyPEM,
+	}, nil
+}
+
+// SignLeaf generates a new key and a leaf certificate for hosts signed by the ca and returns the cert and key as PEM encoded slices.
+// The validity of the leaf certificate is capped at the validity of the ca.
+func (ca *CA) SignLeaf(hosts string, options Options) ([]byte, []byte, error) {
+	if err := options.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+
+	notAfter := now.Add(options.LeafLifetime)
+	if notAfter.After(ca.Cert.NotAfter) {
+		notAfter = ca.Cert.NotAfter
+	}
+
+	leafKey, err := generateKey(options.KeyType)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed createLeafKey for certificate: %w", err)
 	}
 
 	key, err := encodeKey(leafKey)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("failed encodeLeafKey for certificate: %w", err)
+		return nil, nil, fmt.Errorf("failed encodeLeafKey for certificate: %w", err)
 	}
 
-	serialNumber, err = rand.Int(rand.Reader, serialNumberLimit)
+	serialNumber, err := newSerialNumber()
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("failed to generate serial number: %w", err)
+		return nil, nil, err
 	}
 
 	leafTemplate := x509.Certificate{
 		SerialNumber:          serialNumber,
-		NotBefore:             notBefore,
+		NotBefore:             now.Add(-options.ClockSkew),
 		NotAfter:              notAfter,
 		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
@@ -75,33 +228,250 @@ func GenerateCerts(hosts string) ([]byte, []byte, []byte, error) {
 		Subject:               pkix.Name{Organization: []string{"nil2"}},
 	}
 
+	leafTemplate.DNSNames, leafTemplate.IPAddresses = parseHosts(hosts)
+
+	derBytes, err := x509.CreateCertificate(rand.Reader, &leafTemplate, ca.Cert, leafKey.Public(), ca.Key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed createLeaf certificate: %w", err)
+	}
+
+	return encodeCert(derBytes), key, nil
+}
+
+// parseHosts splits a comma-separated list of hosts into DNS names and IP addresses.
+func parseHosts(hosts string) ([]string, []net.IP) {
+	var (
+		dnsNames    []string
+		ipAddresses []net.IP
+	)
+
 	for host := range strings.SplitSeq(hosts, ",") {
 		if ip := net.ParseIP(host); ip != nil {
-			leafTemplate.IPAddresses = append(leafTemplate.IPAddresses, ip)
+			ipAddresses = append(ipAddresses, ip)
 		} else {
-			leafTemplate.DNSNames = append(leafTemplate.DNSNames, host)
+			dnsNames = append(dnsNames, host)
 		}
 	}
 
-	derBytes, err = x509.CreateCertificate(rand.Reader, &leafTemplate, &rootTemplate, &leafKey.PublicKey, rootKey)
+	return dnsNames, ipAddresses
+}
+
+func newSerialNumber() (*big.Int, error) {
+	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
+
+	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("failed createLeaf certificate: %w", err)
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
 	}
 
-	cert := encodeCert(derBytes)
-
-	return ca, cert, key, nil
+	return serialNumber, nil
 }
 
-func encodeKey(key *ecdsa.PrivateKey) ([]byte, error) {
-	b, err := x509.MarshalECPrivateKey(key)
-	if err != nil {
-		return nil, fmt.Errorf("unable to marshal ECDSA private key: %w", err)
+func generateKey(keyType KeyType) (crypto.Signer, error) {
+	switch keyType {
+	case KeyTypeECDSAP256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader) //nolint:wrapcheck
+	case KeyTypeECDSAP384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader) //nolint:wrapcheck
+	case KeyTypeRSA2048:
+		return rsa.GenerateKey(rand.Reader, 2048) //nolint:wrapcheck
+	case KeyTypeRSA3072:
+		return rsa.GenerateKey(rand.Reader, 3072) //nolint:wrapcheck
+	case KeyTypeRSA4096:
+		return rsa.GenerateKey(rand.Reader, 4096) //nolint:wrapcheck
+	case KeyTypeEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+
+		return key, err //nolint:wrapcheck
+	default:
+		return nil, fmt.Errorf("unsupported key type '%s'", keyType)
 	}
+}
 
-	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: b}), nil
+func encodeKey(key crypto.Signer) ([]byte, error) {
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		b, err := x509.MarshalECPrivateKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("unable to marshal ECDSA private key: %w", err)
+		}
+
+		return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: b}), nil
+	case *rsa.PrivateKey:
+		return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)}), nil
+	default:
+		b, err := x509.MarshalPKCS8PrivateKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("unable to marshal private key: %w", err)
+		}
+
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: b}), nil
+	}
 }
 
 func encodeCert(derBytes []byte) []byte {
 	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: derBytes})
 }
+
+// Bundle is a parsed and verified ca, certificate and key triple.
+type Bundle struct {
+	// CA is the ca which issued Cert.
+	CA   *x509.Certificate
+	Cert *x509.Certificate
+}
+
+// ParseBundle parses the PEM encoded ca, cert and key and verifies that they belong together.
+// ca may hold multiple certificates, e.g. during a ca rollover, one of which must have issued cert.
+// It returns an error if the key does not match the certificate or the certificate was not issued by the ca.
+func ParseBundle(ca, cert, key []byte) (*Bundle, error) {
+	caCerts, err := parseCerts(ca)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ca: %w", err)
+	}
+
+	leafCert, err := parseCert(cert)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	if _, err = tls.X509KeyPair(cert, key); err != nil {
+		return nil, fmt.Errorf("key does not match certificate: %w", err)
+	}
+
+	for _, caCert := range caCerts {
+		if err = leafCert.CheckSignatureFrom(caCert); err == nil {
+			return &Bundle{CA: caCert, Cert: leafCert}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("certificate is not signed by ca: %w", err)
+}
+
+// NotAfter returns the point in time at which either the ca or the certificate expires, whichever comes first.
+func (b *Bundle) NotAfter() time.Time {
+	if b.CA.NotAfter.Before(b.Cert.NotAfter) {
+		return b.CA.NotAfter
+	}
+
+	return b.Cert.NotAfter
+}
+
+// NeedsRenewal reports whether the bundle expires within the given duration.
+func (b *Bundle) NeedsRenewal(renewBefore time.Duration) bool {
+	return time.Until(b.NotAfter()) < renewBefore
+}
+
+// CANeedsRenewal reports whether the ca expires within the given duration.
+func (b *Bundle) CANeedsRenewal(renewBefore time.Duration) bool {
+	return time.Until(b.CA.NotAfter) < renewBefore
+}
+
+// CertNeedsRenewal reports whether the certificate expires within the given duration.
+// A certificate never outlives its ca, so a new certificate can be signed by the same ca if only the certificate expires.
+func (b *Bundle) CertNeedsRenewal(renewBefore time.Duration) bool {
+	return time.Until(b.Cert.NotAfter) < renewBefore
+}
+
+// MatchesHosts reports whether the DNS and IP SANs of the certificate are exactly the given comma-separated hosts.
+func (b *Bundle) MatchesHosts(hosts string) bool {
+	dnsNames, ipAddresses := parseHosts(hosts)
+
+	return sameElements(b.Cert.DNSNames, dnsNames) &&
+		sameElements(ipStrings(b.Cert.IPAddresses), ipStrings(ipAddresses))
+}
+
+func ipStrings(ips []net.IP) []string {
+	s := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		s = append(s, ip.String())
+	}
+
+	return s
+}
+
+func sameElements(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+
+	return slices.Equal(slices.Compact(a), slices.Compact(b))
+}
+
+// parseCerts parses all PEM encoded certificates in data.
+func parseCerts(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+
+	for {
+		var block *pem.Block
+
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse certificate: %w", err)
+		}
+
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM encoded certificate found")
+	}
+
+	return certs, nil
+}
+
+func parseCert(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("no PEM encoded certificate found")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse certificate: %w", err)
+	}
+
+	return cert, nil
+}
+
+func parseKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM encoded key found")
+	}
+
+	var (
+		key any
+		err error
+	)
+
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type '%s'", block.Type)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse key: %w", err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+
+	return signer, nil
+}
@@ -7,7 +7,9 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -19,7 +21,7 @@ func handler(w http.ResponseWriter, _ *http.Request) {
 func TestCertificateCreation(t *testing.T) {
 	t.Parallel()
 
-	ca, cert, key, err := GenerateCerts("localhost")
+	ca, cert, key, err := GenerateCerts("localhost", DefaultOptions())
 	require.NoError(t, err)
 
 	c, err := tls.X509KeyPair(cert, key)
@@ -60,3 +62,207 @@ func TestCertificateCreation(t *testing.T) {
 	require.Equal(t, []byte("Hello World"), body)
 	require.NoError(t, res.Body.Close())
 }
+
+func TestParseBundle(t *testing.T) {
+	t.Parallel()
+
+	ca, cert, key, err := GenerateCerts("localhost", DefaultOptions())
+	require.NoError(t, err)
+
+	t.Run("valid", func(t *testing.T) {
+		t.Parallel()
+
+		bundle, err := ParseBundle(ca, cert, key)
+		require.NoError(t, err)
+		require.Equal(t, []string{"localhost"}, bundle.Cert.DNSNames)
+		require.False(t, bundle.NeedsRenewal(30*24*time.Hour))
+		require.True(t, bundle.NeedsRenewal(200*365*24*time.Hour))
+	})
+
+	t.Run("certificate_expires_before_ca", func(t *testing.T) {
+		t.Parallel()
+
+		options := DefaultOptions()
+		options.LeafLifetime = 24 * time.Hour
+
+		ca, err := GenerateCA(options)
+		require.NoError(t, err)
+
+		cert, key, err := ca.SignLeaf("localhost", options)
+		require.NoError(t, err)
+
+		bundle, err := ParseBundle(ca.CertPEM, cert, key)
+		require.NoError(t, err)
+		require.True(t, bundle.CertNeedsRenewal(48*time.Hour))
+		require.False(t, bundle.CANeedsRenewal(48*time.Hour))
+	})
+
+	t.Run("key_does_not_match_certificate", func(t *testing.T) {
+		t.Parallel()
+
+		_, _, otherKey, err := GenerateCerts("localhost", DefaultOptions())
+		require.NoError(t, err)
+
+		_, err = ParseBundle(ca, cert, otherKey)
+		require.Error(t, err)
+	})
+
+	t.Run("certificate_not_signed_by_ca", func(t *testing.T) {
+		t.Parallel()
+
+		otherCa, _, _, err := GenerateCerts("localhost", DefaultOptions())
+		require.NoError(t, err)
+
+		_, err = ParseBundle(otherCa, cert, key)
+		require.Error(t, err)
+	})
+
+	t.Run("ca_bundle", func(t *testing.T) {
+		t.Parallel()
+
+		otherCa, _, _, err := GenerateCerts("localhost", DefaultOptions())
+		require.NoError(t, err)
+
+		bundle, err := ParseBundle(append(append([]byte{}, otherCa...), ca...), cert, key)
+		require.NoError(t, err)
+		require.NoError(t, bundle.Cert.CheckSignatureFrom(bundle.CA))
+	})
+
+	t.Run("invalid_pem", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := ParseBundle([]byte("foo"), cert, key)
+		require.Error(t, err)
+	})
+}
+
+func TestGenerateCertsOptions(t *testing.T) {
+	t.Parallel()
+
+	for _, keyType := range []KeyType{KeyTypeECDSAP256, KeyTypeECDSAP384, KeyTypeRSA2048, KeyTypeEd25519} {
+		t.Run(string(keyType), func(t *testing.T) {
+			t.Parallel()
+
+			options := Options{
+				KeyType:      keyType,
+				CALifetime:   365 * 24 * time.Hour,
+				LeafLifetime: 24 * time.Hour,
+				ClockSkew:    time.Minute,
+			}
+
+			ca, cert, key, err := GenerateCerts("localhost,127.0.0.1", options)
+			require.NoError(t, err)
+
+			bundle, err := ParseBundle(ca, cert, key)
+			require.NoError(t, err)
+
+			require.WithinDuration(t, time.Now().Add(options.CALifetime), bundle.CA.NotAfter, time.Minute)
+			require.WithinDuration(t, time.Now().Add(options.LeafLifetime), bundle.Cert.NotAfter, time.Minute)
+			require.WithinDuration(t, time.Now().Add(-options.ClockSkew), bundle.Cert.NotBefore, time.Minute)
+			require.Equal(t, []string{"localhost"}, bundle.Cert.DNSNames)
+			require.Len(t, bundle.Cert.IPAddresses, 1)
+		})
+	}
+
+	t.Run("returns_error_when", func(t *testing.T) {
+		t.Parallel()
+
+		for name, mutateF := range map[string]func(*Options){
+			"key_type_is_unsupported":           func(o *Options) { o.KeyType = "dsa" },
+			"ca_lifetime_is_zero":               func(o *Options) { o.CALifetime = 0 },
+			"leaf_lifetime_exceeds_ca_lifetime": func(o *Options) { o.LeafLifetime = o.CALifetime + time.Hour },
+			"clock_skew_is_negative":            func(o *Options) { o.ClockSkew = -time.Minute },
+		} {
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+
+				options := DefaultOptions()
+				mutateF(&options)
+
+				_, _, _, err := GenerateCerts("localhost", options)
+				require.Error(t, err)
+			})
+		}
+	})
+}
+
+func TestMatchesHosts(t *testing.T) {
+	t.Parallel()
+
+	ca, cert, key, err := GenerateCerts("svc.ns.svc,svc,10.0.0.1", DefaultOptions())
+	require.NoError(t, err)
+
+	bundle, err := ParseBundle(ca, cert, key)
+	require.NoError(t, err)
+
+	require.True(t, bundle.MatchesHosts("svc.ns.svc,svc,10.0.0.1"))
+	require.True(t, bundle.MatchesHosts("10.0.0.1,svc,svc.ns.svc"))
+	require.False(t, bundle.MatchesHosts("svc.ns.svc,svc"))
+	require.False(t, bundle.MatchesHosts("svc.ns.svc,svc,10.0.0.2"))
+	require.False(t, bundle.MatchesHosts("svc.ns.svc,svc,svc.ns.svc.cluster.local,10.0.0.1"))
+}
+
+func TestLoadCA(t *testing.T) {
+	t.Parallel()
+
+	options := DefaultOptions()
+
+	ca, err := GenerateCA(options)
+	require.NoError(t, err)
+
+	loaded, err := LoadCA(ca.CertPEM, ca.KeyPEM)
+	require.NoError(t, err)
+
+	cert, key, err := loaded.SignLeaf("localhost", options)
+	require.NoError(t, err)
+
+	_, err = ParseBundle(ca.CertPEM, cert, key)
+	require.NoError(t, err)
+
+	otherCA, err := GenerateCA(options)
+	require.NoError(t, err)
+
+	_, err = LoadCA(ca.CertPEM, otherCA.KeyPEM)
+	require.Error(t, err)
+
+	chain := append(slices.Clone(ca.CertPEM), otherCA.CertPEM...)
+
+	loaded, err = LoadCA(chain, ca.KeyPEM)
+	require.NoError(t, err)
+	require.Equal(t, chain, loaded.CertPEM)
+
+	_, err = LoadCA(cert, key)
+	require.Error(t, err)
+}
+
+func TestDescribe(t *testing.T) {
+	t.Parallel()
+
+	options := DefaultOptions()
+	options.KeyType = KeyTypeRSA2048
+
+	ca, err := GenerateCA(options)
+	require.NoError(t, err)
+
+	cert, _, err := ca.SignLeaf("localhost,127.0.0.1", options)
+	require.NoError(t, err)
+
+	caInfo, err := Describe(ca.CertPEM)
+	require.NoError(t, err)
+	require.True(t, caInfo.IsCA)
+	require.Equal(t, KeyTypeRSA2048, caInfo.KeyType)
+	require.Equal(t, caInfo.Subject, caInfo.Issuer)
+
+	info, err := Describe(cert)
+	require.NoError(t, err)
+	require.False(t, info.IsCA)
+	require.Equal(t, KeyTypeRSA2048, info.KeyType)
+	require.Equal(t, caInfo.Subject, info.Issuer)
+	require.Equal(t, []string{"localhost"}, info.DNSNames)
+	require.Equal(t, []string{"127.0.0.1"}, info.IPAddresses)
+	require.Len(t, info.SHA256Fingerprint, 32*3-1)
+	require.NotEqual(t, caInfo.SHA256Fingerprint, info.SHA256Fingerprint)
+
+	_, err = Describe([]byte("not a certificate"))
+	require.Error(t, err)
+}
@@ -0,0 +1,79 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha1" //nolint:gosec // SHA-1 fingerprints are printed for compatibility with common tooling, not used for security
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Info describes a certificate. The field names are stable as they are part of the inspect output.
+type Info struct {
+	Subject           string    `json:"subject"`
+	Issuer            string    `json:"issuer"`
+	SerialNumber      string    `json:"serialNumber"`
+	DNSNames          []string  `json:"dnsNames,omitempty"`
+	IPAddresses       []string  `json:"ipAddresses,omitempty"`
+	NotBefore         time.Time `json:"notBefore"`
+	NotAfter          time.Time `json:"notAfter"`
+	IsCA              bool      `json:"isCA"`
+	KeyType           KeyType   `json:"keyType"`
+	SHA1Fingerprint   string    `json:"sha1Fingerprint"`
+	SHA256Fingerprint string    `json:"sha256Fingerprint"`
+}
+
+// Describe parses the first certificate of a PEM encoded certificate and returns its description.
+func Describe(certPEM []byte) (*Info, error) {
+	cert, err := parseCert(certPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	sha1Sum := sha1.Sum(cert.Raw) //nolint:gosec
+	sha256Sum := sha256.Sum256(cert.Raw)
+
+	return &Info{
+		Subject:           cert.Subject.String(),
+		Issuer:            cert.Issuer.String(),
+		SerialNumber:      cert.SerialNumber.Text(16),
+		DNSNames:          cert.DNSNames,
+		IPAddresses:       ipStrings(cert.IPAddresses),
+		NotBefore:         cert.NotBefore,
+		NotAfter:          cert.NotAfter,
+		IsCA:              cert.IsCA,
+		KeyType:           publicKeyType(cert),
+		SHA1Fingerprint:   formatFingerprint(sha1Sum[:]),
+		SHA256Fingerprint: formatFingerprint(sha256Sum[:]),
+	}, nil
+}
+
+// publicKeyType returns the KeyType of the public key of cert.
+// Keys that cannot be generated by kube-webhook-certgen are described by their algorithm and size.
+func publicKeyType(cert *x509.Certificate) KeyType {
+	switch key := cert.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		return KeyType(fmt.Sprintf("ecdsa-p%d", key.Curve.Params().BitSize))
+	case *rsa.PublicKey:
+		return KeyType(fmt.Sprintf("rsa-%d", key.N.BitLen()))
+	case ed25519.PublicKey:
+		return KeyTypeEd25519
+	default:
+		return KeyType(strings.ToLower(cert.PublicKeyAlgorithm.String()))
+	}
+}
+
+// formatFingerprint formats sum as colon separated upper case hex, as printed by openssl.
+func formatFingerprint(sum []byte) string {
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = strings.ToUpper(hex.EncodeToString([]byte{b}))
+	}
+
+	return strings.Join(parts, ":")
+}
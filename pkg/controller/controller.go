@@ -0,0 +1,255 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	apiextensionsclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	apiextensionsinformers "k8s.io/apiextensions-apiserver/pkg/client/informers/externalversions/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	admissionregistrationinformers "k8s.io/client-go/informers/admissionregistration/v1"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	apiregistrationv1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
+	"k8s.io/kube-aggregator/pkg/client/clientset_generated/clientset"
+)
+
+// queueKey is the only key ever added to the queue. Every change on any watched object
+// results in the same full reconciliation, so there is no need to distinguish them.
+const queueKey = "reconcile"
+
+// Options selects the objects watched by the Controller.
+type Options struct {
+	SecretName                          string
+	Namespace                           string
+	ValidatingWebhookConfigurationNames []string
+	MutatingWebhookConfigurationNames   []string
+	APIServiceNames                     []string
+	CustomResourceDefinitionNames       []string
+	// Discover watches all ValidatingWebhookConfigurations, MutatingWebhookConfigurations, APIServices and
+	// CustomResourceDefinitions matching LabelSelector, so objects selected by discovery are reconciled as well.
+	Discover      bool
+	LabelSelector string
+	// ResyncPeriod is the interval in which a reconciliation is triggered even if no object changed.
+	ResyncPeriod time.Duration
+}
+
+// ReconcileFunc brings the certificate secret and all patched objects into the desired state.
+type ReconcileFunc func(ctx context.Context) error
+
+// Controller watches the certificate secret and the objects carrying its ca and calls a ReconcileFunc whenever one of them changes.
+type Controller struct {
+	clientSet kubernetes.Interface
+	queue     workqueue.TypedRateLimitingInterface[string]
+	reconcile ReconcileFunc
+	// isLeader reports whether this instance may reconcile. If nil, it always may.
+	isLeader  func() bool
+	informers []cache.SharedIndexInformer
+}
+
+// New creates a new Controller with the provided client sets.
+func New(
+	clientSet kubernetes.Interface,
+	aggregatorClientSet clientset.Interface,
+	apiExtensionsClientSet apiextensionsclientset.Interface,
+	options Options,
+	reconcile ReconcileFunc,
+) (*Controller, error) {
+	if clientSet == nil {
+		return nil, errors.New("no kubernetes client given")
+	}
+
+	if aggregatorClientSet == nil {
+		return nil, errors.New("no kubernetes aggregator client given")
+	}
+
+	if apiExtensionsClientSet == nil {
+		return nil, errors.New("no kubernetes apiextensions client given")
+	}
+
+	if reconcile == nil {
+		return nil, errors.New("no reconcile function given")
+	}
+
+	c := &Controller{
+		clientSet: clientSet,
+		queue:     workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[string]()),
+		reconcile: reconcile,
+	}
+
+	c.informers = append(c.informers, coreinformers.NewFilteredSecretInformer(
+		clientSet, options.Namespace, options.ResyncPeriod, cache.Indexers{}, byName(options.SecretName),
+	))
+
+	for _, name := range options.ValidatingWebhookConfigurationNames {
+		c.informers = append(c.informers, admissionregistrationinformers.NewFilteredValidatingWebhookConfigurationInformer(
+			clientSet, options.ResyncPeriod, cache.Indexers{}, byName(name),
+		))
+	}
+
+	for _, name := range options.MutatingWebhookConfigurationNames {
+		c.informers = append(c.informers, admissionregistrationinformers.NewFilteredMutatingWebhookConfigurationInformer(
+			clientSet, options.ResyncPeriod, cache.Indexers{}, byName(name),
+		))
+	}
+
+	for _, name := range options.APIServiceNames {
+		c.informers = append(c.informers, newAPIServiceInformer(aggregatorClientSet, byName(name), options.ResyncPeriod))
+	}
+
+	for _, name := range options.CustomResourceDefinitionNames {
+		c.informers = append(c.informers, apiextensionsinformers.NewFilteredCustomResourceDefinitionInformer(
+			apiExtensionsClientSet, options.ResyncPeriod, cache.Indexers{}, byName(name),
+		))
+	}
+
+	if options.Discover {
+		tweakListOptions := byLabelSelector(options.LabelSelector)
+
+		c.informers = append(c.informers,
+			admissionregistrationinformers.NewFilteredValidatingWebhookConfigurationInformer(
+				clientSet, options.ResyncPeriod, cache.Indexers{}, tweakListOptions,
+			),
+			admissionregistrationinformers.NewFilteredMutatingWebhookConfigurationInformer(
+				clientSet, options.ResyncPeriod, cache.Indexers{}, tweakListOptions,
+			),
+			newAPIServiceInformer(aggregatorClientSet, tweakListOptions, options.ResyncPeriod),
+			apiextensionsinformers.NewFilteredCustomResourceDefinitionInformer(
+				apiExtensionsClientSet, options.ResyncPeriod, cache.Indexers{}, tweakListOptions,
+			),
+		)
+	}
+
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(any) { c.queue.Add(queueKey) },
+		UpdateFunc: func(any, any) { c.queue.Add(queueKey) },
+		DeleteFunc: func(any) { c.queue.Add(queueKey) },
+	}
+
+	for _, informer := range c.informers {
+		if _, err := informer.AddEventHandler(handler); err != nil {
+			return nil, fmt.Errorf("failed to add event handler: %w", err)
+		}
+	}
+
+	return c, nil
+}
+
+// Run starts watching and reconciles until ctx is cancelled.
+func (c *Controller) Run(ctx context.Context) error {
+	defer c.queue.ShutDown()
+
+	syncs := make([]cache.InformerSynced, 0, len(c.informers))
+
+	for _, informer := range c.informers {
+		go informer.Run(ctx.Done())
+
+		syncs = append(syncs, informer.HasSynced)
+	}
+
+	slog.InfoContext(ctx, "waiting for informer caches to sync")
+
+	if !cache.WaitForCacheSync(ctx.Done(), syncs...) {
+		return errors.New("failed to wait for informer caches to sync")
+	}
+
+	// Reconcile once on startup, even if no watched object exists yet.
+	c.queue.Add(queueKey)
+
+	go func() {
+		<-ctx.Done()
+		c.queue.ShutDown()
+	}()
+
+	slog.InfoContext(ctx, "controller started")
+
+	for {
+		if !c.processNextItem(ctx) {
+			break
+		}
+	}
+
+	slog.InfoContext(ctx, "controller stopped")
+
+	return nil
+}
+
+func (c *Controller) processNextItem(ctx context.Context) bool {
+	key, shutdown := c.queue.Get()
+	if shutdown {
+		return false
+	}
+
+	defer c.queue.Done(key)
+
+	if c.isLeader != nil && !c.isLeader() {
+		slog.DebugContext(ctx, "not the leader, skipping reconciliation")
+		c.queue.Forget(key)
+
+		return true
+	}
+
+	if err := c.reconcile(ctx); err != nil {
+		slog.ErrorContext(ctx, "reconciliation failed, retrying",
+			slog.Any("err", err),
+			slog.Int("retries", c.queue.NumRequeues(key)),
+		)
+
+		c.queue.AddRateLimited(key)
+
+		return true
+	}
+
+	c.queue.Forget(key)
+
+	return true
+}
+
+// newAPIServiceInformer constructs an informer for the APIServices selected by tweakListOptions.
+func newAPIServiceInformer(
+	aggregatorClientSet clientset.Interface,
+	tweakListOptions func(*metav1.ListOptions),
+	resyncPeriod time.Duration,
+) cache.SharedIndexInformer {
+	client := aggregatorClientSet.ApiregistrationV1().APIServices()
+
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListWithContextFunc: func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+				tweakListOptions(&options)
+
+				return client.List(ctx, options) //nolint:wrapcheck
+			},
+			WatchFuncWithContext: func(ctx context.Context, options metav1.ListOptions) (watch.Interface, error) {
+				tweakListOptions(&options)
+
+				return client.Watch(ctx, options) //nolint:wrapcheck
+			},
+		},
+		&apiregistrationv1.APIService{},
+		resyncPeriod,
+		cache.Indexers{},
+	)
+}
+
+// byName restricts list and watch calls of an informer to the object with the given name.
+func byName(name string) func(*metav1.ListOptions) {
+	return func(options *metav1.ListOptions) {
+		options.FieldSelector = fields.OneTermEqualSelector(metav1.ObjectNameField, name).String()
+	}
+}
+
+// byLabelSelector restricts list and watch calls of an informer to objects matching the label selector.
+func byLabelSelector(selector string) func(*metav1.ListOptions) {
+	return func(options *metav1.ListOptions) {
+		options.LabelSelector = selector
+	}
+}
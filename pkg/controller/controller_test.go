@@ -0,0 +1,203 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admissionregistration/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	apiregistrationv1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
+	aggregatorfake "k8s.io/kube-aggregator/pkg/client/clientset_generated/clientset/fake"
+)
+
+const (
+	testWebhookName    = "c7c95710-d8c3-4cc3-a2a8-8d2b46909c76"
+	testSecretName     = "15906410-af2a-4f9b-8a2d-c08ffdd5e129"
+	testAPIServiceName = "37f6a2d1-b401-4275-833b-9ff5004f0301"
+	testCRDName        = "widgets.example.com"
+	testNamespace      = "7cad5f92-c0d5-4bc9-87a3-6f44d5a5619d"
+)
+
+func TestController(t *testing.T) {
+	t.Parallel()
+
+	clientSet := fake.NewSimpleClientset(&admissionv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: testWebhookName},
+		Webhooks:   []admissionv1.ValidatingWebhook{{Name: "v1"}},
+	})
+	aggregatorClientSet := aggregatorfake.NewSimpleClientset(&apiregistrationv1.APIService{
+		ObjectMeta: metav1.ObjectMeta{Name: testAPIServiceName},
+	})
+	apiExtensionsClientSet := apiextensionsfake.NewSimpleClientset(newTestCRD(nil))
+
+	reconciled := make(chan struct{}, 10)
+
+	var calls atomic.Int32
+
+	c, err := New(clientSet, aggregatorClientSet, apiExtensionsClientSet, Options{
+		SecretName:                          testSecretName,
+		Namespace:                           testNamespace,
+		ValidatingWebhookConfigurationNames: []string{testWebhookName},
+		APIServiceNames:                     []string{testAPIServiceName},
+		CustomResourceDefinitionNames:       []string{testCRDName},
+	}, func(context.Context) error {
+		reconciled <- struct{}{}
+
+		// Fail the first reconciliation to verify it is retried.
+		if calls.Add(1) == 1 {
+			return errors.New("transient error")
+		}
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(t.Context())
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- c.Run(ctx)
+	}()
+
+	waitForReconcile(t, reconciled)
+
+	t.Run("retries_failed_reconciliation", func(t *testing.T) {
+		waitForReconcile(t, reconciled)
+	})
+
+	t.Run("reconciles_when_webhook_configuration_changes", func(t *testing.T) {
+		drainReconciles(reconciled)
+
+		_, err := clientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Update(ctx, &admissionv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: testWebhookName},
+			Webhooks:   []admissionv1.ValidatingWebhook{{Name: "v1", ClientConfig: admissionv1.WebhookClientConfig{CABundle: []byte("foo")}}},
+		}, metav1.UpdateOptions{})
+		require.NoError(t, err)
+
+		waitForReconcile(t, reconciled)
+	})
+
+	t.Run("reconciles_when_api_service_changes", func(t *testing.T) {
+		drainReconciles(reconciled)
+
+		_, err := aggregatorClientSet.ApiregistrationV1().APIServices().Update(ctx, &apiregistrationv1.APIService{
+			ObjectMeta: metav1.ObjectMeta{Name: testAPIServiceName},
+			Spec:       apiregistrationv1.APIServiceSpec{CABundle: []byte("foo")},
+		}, metav1.UpdateOptions{})
+		require.NoError(t, err)
+
+		waitForReconcile(t, reconciled)
+	})
+
+	t.Run("reconciles_when_crd_changes", func(t *testing.T) {
+		drainReconciles(reconciled)
+
+		_, err := apiExtensionsClientSet.ApiextensionsV1().CustomResourceDefinitions().Update(ctx, newTestCRD([]byte("foo")), metav1.UpdateOptions{})
+		require.NoError(t, err)
+
+		waitForReconcile(t, reconciled)
+	})
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestControllerDiscover(t *testing.T) {
+	t.Parallel()
+
+	clientSet := fake.NewSimpleClientset()
+	reconciled := make(chan struct{}, 10)
+
+	c, err := New(clientSet, aggregatorfake.NewSimpleClientset(), apiextensionsfake.NewSimpleClientset(), Options{
+		SecretName:    testSecretName,
+		Namespace:     testNamespace,
+		Discover:      true,
+		LabelSelector: "app.kubernetes.io/name=foo",
+	}, func(context.Context) error {
+		reconciled <- struct{}{}
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(t.Context())
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- c.Run(ctx)
+	}()
+
+	waitForReconcile(t, reconciled)
+	drainReconciles(reconciled)
+
+	_, err = clientSet.AdmissionregistrationV1().MutatingWebhookConfigurations().Create(ctx, &admissionv1.MutatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "discovered", Labels: map[string]string{"app.kubernetes.io/name": "foo"}},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	waitForReconcile(t, reconciled)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	reconcile := func(context.Context) error { return nil }
+
+	_, err := New(nil, aggregatorfake.NewSimpleClientset(), apiextensionsfake.NewSimpleClientset(), Options{}, reconcile)
+	require.Error(t, err)
+
+	_, err = New(fake.NewSimpleClientset(), nil, apiextensionsfake.NewSimpleClientset(), Options{}, reconcile)
+	require.Error(t, err)
+
+	_, err = New(fake.NewSimpleClientset(), aggregatorfake.NewSimpleClientset(), nil, Options{}, reconcile)
+	require.Error(t, err)
+
+	_, err = New(fake.NewSimpleClientset(), aggregatorfake.NewSimpleClientset(), apiextensionsfake.NewSimpleClientset(), Options{}, nil)
+	require.Error(t, err)
+}
+
+func newTestCRD(caBundle []byte) *apiextensionsv1.CustomResourceDefinition {
+	return &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: testCRDName},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Conversion: &apiextensionsv1.CustomResourceConversion{
+				Strategy: apiextensionsv1.WebhookConverter,
+				Webhook: &apiextensionsv1.WebhookConversion{
+					ClientConfig: &apiextensionsv1.WebhookClientConfig{CABundle: caBundle},
+				},
+			},
+		},
+	}
+}
+
+func waitForReconcile(t *testing.T, reconciled <-chan struct{}) {
+	t.Helper()
+
+	select {
+	case <-reconciled:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for reconciliation")
+	}
+}
+
+func drainReconciles(reconciled <-chan struct{}) {
+	for {
+		select {
+		case <-reconciled:
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// LeaderElectionOptions configures the Lease used to elect a single active Controller.
+type LeaderElectionOptions struct {
+	LeaseName      string
+	LeaseNamespace string
+	// Identity must be unique across all replicas, e.g. the pod name.
+	Identity      string
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+}
+
+// ErrLeadershipLost is returned by RunWithLeaderElection if the lease could not be renewed.
+var ErrLeadershipLost = errors.New("leader election lost")
+
+// RunWithLeaderElection starts watching like Run, but only reconciles while holding the lease.
+// It returns ErrLeadershipLost if the lease is lost before ctx is cancelled. On cancellation, the lease is released.
+func (c *Controller) RunWithLeaderElection(ctx context.Context, options LeaderElectionOptions) error {
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.LeaseLock{
+			LeaseMeta: metav1.ObjectMeta{
+				Name:      options.LeaseName,
+				Namespace: options.LeaseNamespace,
+			},
+			Client:     c.clientSet.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{Identity: options.Identity},
+		},
+		Name:            options.LeaseName,
+		LeaseDuration:   options.LeaseDuration,
+		RenewDeadline:   options.RenewDeadline,
+		RetryPeriod:     options.RetryPeriod,
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				slog.InfoContext(ctx, "started leading",
+					slog.String("identity", options.Identity),
+				)
+
+				c.queue.Add(queueKey)
+			},
+			OnStoppedLeading: func() {
+				slog.InfoContext(ctx, "stopped leading",
+					slog.String("identity", options.Identity),
+				)
+			},
+			OnNewLeader: func(identity string) {
+				if identity != options.Identity {
+					slog.InfoContext(ctx, "new leader elected",
+						slog.String("identity", identity),
+					)
+				}
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create leader elector: %w", err)
+	}
+
+	c.isLeader = elector.IsLeader
+
+	electionCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	electionDone := make(chan struct{})
+
+	go func() {
+		defer close(electionDone)
+
+		elector.Run(electionCtx)
+
+		// Run returns if the lease was lost. Stop the controller as well.
+		cancel()
+	}()
+
+	err = c.Run(electionCtx)
+
+	cancel()
+	<-electionDone
+
+	if err != nil {
+		return err
+	}
+
+	if ctx.Err() == nil {
+		return ErrLeadershipLost
+	}
+
+	return nil
+}
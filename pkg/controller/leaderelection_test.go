@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	apiextensionsfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	aggregatorfake "k8s.io/kube-aggregator/pkg/client/clientset_generated/clientset/fake"
+)
+
+func TestRunWithLeaderElection(t *testing.T) {
+	t.Parallel()
+
+	clientSet := fake.NewSimpleClientset()
+	aggregatorClientSet := aggregatorfake.NewSimpleClientset()
+
+	newElectedController := func(identity string) (<-chan struct{}, context.CancelFunc, <-chan error) {
+		reconciled := make(chan struct{}, 10)
+
+		c, err := New(clientSet, aggregatorClientSet, apiextensionsfake.NewSimpleClientset(), Options{
+			SecretName: testSecretName,
+			Namespace:  testNamespace,
+		}, func(context.Context) error {
+			reconciled <- struct{}{}
+
+			return nil
+		})
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(t.Context())
+		done := make(chan error, 1)
+
+		go func() {
+			done <- c.RunWithLeaderElection(ctx, LeaderElectionOptions{
+				LeaseName:      "kube-webhook-certgen",
+				LeaseNamespace: testNamespace,
+				Identity:       identity,
+				LeaseDuration:  2 * time.Second,
+				RenewDeadline:  time.Second,
+				RetryPeriod:    100 * time.Millisecond,
+			})
+		}()
+
+		return reconciled, cancel, done
+	}
+
+	leaderReconciled, cancelLeader, leaderDone := newElectedController("leader")
+	waitForReconcile(t, leaderReconciled)
+
+	followerReconciled, cancelFollower, followerDone := newElectedController("follower")
+
+	select {
+	case <-followerReconciled:
+		t.Fatal("follower must not reconcile while the leader holds the lease")
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	// Cancelling the leader releases the lease, the follower takes over.
+	cancelLeader()
+	require.NoError(t, <-leaderDone)
+
+	waitForReconcile(t, followerReconciled)
+
+	cancelFollower()
+	require.NoError(t, <-followerDone)
+}
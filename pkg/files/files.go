@@ -0,0 +1,233 @@
+// Package files stores certificates as PEM encoded files in a directory, e.g. for local development
+// or init containers that cannot access the API server.
+package files
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/jkroepke/kube-webhook-certgen/pkg/k8s"
+)
+
+// ErrNoFiles is returned by Read if none of the files exist.
+var ErrNoFiles = errors.New("certificate files not found")
+
+// Options configures the files written by Write and read by Read.
+type Options struct {
+	Dir      string
+	CAName   string
+	CertName string
+	KeyName  string
+	// CAKeyName is the name of the ca key file. If empty, the ca key is neither read nor written.
+	CAKeyName string
+	// RolloverName is the name of the file holding the state of a ca rollover. If empty, the state is neither read nor written.
+	RolloverName string
+	// CertMode is the mode of the ca and the certificate file.
+	CertMode fs.FileMode
+	// KeyMode is the mode of the key and the ca key file.
+	KeyMode fs.FileMode
+}
+
+// DefaultOptions returns the file names used by kubernetes.io/tls secrets, which most webhook servers expect.
+func DefaultOptions(dir string) Options {
+	return Options{
+		Dir:          dir,
+		CAName:       "ca.crt",
+		CertName:     "tls.crt",
+		KeyName:      "tls.key",
+		CAKeyName:    "ca.key",
+		RolloverName: "ca-rollover.json",
+		CertMode:     0o644,
+		KeyMode:      0o600,
+	}
+}
+
+// Write writes the ca, the certificate and the key to the directory, creating it if needed.
+// The ca key and the rollover state are written if set and removed otherwise, so they never belong to an older ca.
+// Each file is replaced atomically, so readers never observe a partially written file.
+func Write(options Options, certs *k8s.Certificates) error {
+	if err := os.MkdirAll(options.Dir, 0o755); err != nil { //nolint:gosec // the directory holds no secret itself
+		return fmt.Errorf("failed to create directory '%s': %w", options.Dir, err)
+	}
+
+	for _, file := range []struct {
+		name string
+		data []byte
+		mode fs.FileMode
+	}{
+		{options.CAName, certs.CA, options.CertMode},
+		{options.CertName, certs.Cert, options.CertMode},
+		{options.KeyName, certs.Key, options.KeyMode},
+	} {
+		if err := writeFile(filepath.Join(options.Dir, file.name), file.data, file.mode); err != nil {
+			return err
+		}
+	}
+
+	if err := writeOptionalFile(options.Dir, options.CAKeyName, certs.CAKey, options.KeyMode); err != nil {
+		return err
+	}
+
+	var rollover []byte
+
+	if certs.Rollover != nil {
+		var err error
+
+		if rollover, err = json.Marshal(k8s.RolloverAnnotations(certs.Rollover)); err != nil {
+			return fmt.Errorf("failed to encode rollover state: %w", err)
+		}
+	}
+
+	return writeOptionalFile(options.Dir, options.RolloverName, rollover, options.CertMode)
+}
+
+// Read reads the ca, the certificate and the key from the directory, and the ca key and the rollover state if they exist.
+// It returns ErrNoFiles if none of the ca, the certificate and the key exist, or an error if only some of them exist.
+func Read(options Options) (*k8s.Certificates, error) {
+	certs := &k8s.Certificates{}
+	missing := 0
+
+	for _, file := range []struct {
+		name string
+		data *[]byte
+	}{
+		{options.CAName, &certs.CA},
+		{options.CertName, &certs.Cert},
+		{options.KeyName, &certs.Key},
+	} {
+		data, err := os.ReadFile(filepath.Join(options.Dir, file.name))
+
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			missing++
+		case err != nil:
+			return nil, fmt.Errorf("failed to read '%s': %w", file.name, err)
+		default:
+			*file.data = data
+		}
+	}
+
+	switch missing {
+	case 0:
+		return certs, readOptionalFiles(options, certs)
+	case 3:
+		return nil, ErrNoFiles
+	default:
+		return nil, fmt.Errorf("incomplete certificate files in '%s': expected '%s', '%s' and '%s'", options.Dir, options.CAName, options.CertName, options.KeyName)
+	}
+}
+
+// ReadCA reads the ca from the directory. It returns ErrNoFiles if the ca file does not exist.
+func ReadCA(options Options) ([]byte, error) {
+	ca, err := os.ReadFile(filepath.Join(options.Dir, options.CAName))
+
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, ErrNoFiles
+	case err != nil:
+		return nil, fmt.Errorf("failed to read '%s': %w", options.CAName, err)
+	default:
+		return ca, nil
+	}
+}
+
+// readOptionalFiles reads the ca key and the rollover state into certs, if they exist.
+func readOptionalFiles(options Options, certs *k8s.Certificates) error {
+	caKey, err := readOptionalFile(options.Dir, options.CAKeyName)
+	if err != nil {
+		return err
+	}
+
+	certs.CAKey = caKey
+
+	rollover, err := readOptionalFile(options.Dir, options.RolloverName)
+	if err != nil || rollover == nil {
+		return err
+	}
+
+	var annotations map[string]string
+	if err = json.Unmarshal(rollover, &annotations); err != nil {
+		return fmt.Errorf("invalid rollover state in '%s': %w", options.RolloverName, err)
+	}
+
+	if certs.Rollover, err = k8s.ParseRollover(annotations); err != nil {
+		return fmt.Errorf("invalid rollover state in '%s': %w", options.RolloverName, err)
+	}
+
+	return nil
+}
+
+// readOptionalFile reads the file name from dir. It returns nil if name is empty or the file does not exist.
+func readOptionalFile(dir, name string) ([]byte, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, name))
+
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to read '%s': %w", name, err)
+	default:
+		return data, nil
+	}
+}
+
+// writeOptionalFile writes data to the file name in dir, or removes the file if data is empty. Nothing is done if name is empty.
+func writeOptionalFile(dir, name string, data []byte, mode fs.FileMode) error {
+	if name == "" {
+		return nil
+	}
+
+	path := filepath.Join(dir, name)
+
+	if len(data) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove '%s': %w", path, err)
+		}
+
+		return nil
+	}
+
+	return writeFile(path, data, mode)
+}
+
+// writeFile writes data to a temporary file next to path and renames it to path.
+func writeFile(path string, data []byte, mode fs.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create '%s': %w", path, err)
+	}
+
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+
+		return fmt.Errorf("failed to write '%s': %w", path, err)
+	}
+
+	if err = tmp.Chmod(mode); err != nil {
+		_ = tmp.Close()
+
+		return fmt.Errorf("failed to set mode of '%s': %w", path, err)
+	}
+
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write '%s': %w", path, err)
+	}
+
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write '%s': %w", path, err)
+	}
+
+	return nil
+}
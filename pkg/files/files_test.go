@@ -0,0 +1,97 @@
+package files
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jkroepke/kube-webhook-certgen/pkg/k8s"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteThenRead(t *testing.T) {
+	t.Parallel()
+
+	options := DefaultOptions(filepath.Join(t.TempDir(), "certs"))
+
+	_, err := Read(options)
+	require.ErrorIs(t, err, ErrNoFiles)
+
+	certs := &k8s.Certificates{CA: []byte("ca"), Cert: []byte("cert"), Key: []byte("key")}
+	require.NoError(t, Write(options, certs))
+
+	for name, mode := range map[string]fs.FileMode{"ca.crt": 0o644, "tls.crt": 0o644, "tls.key": 0o600} {
+		info, err := os.Stat(filepath.Join(options.Dir, name))
+		require.NoError(t, err)
+		require.Equal(t, mode, info.Mode().Perm(), name)
+	}
+
+	read, err := Read(options)
+	require.NoError(t, err)
+	require.Equal(t, certs, read)
+
+	ca, err := ReadCA(options)
+	require.NoError(t, err)
+	require.Equal(t, "ca", string(ca))
+
+	entries, err := os.ReadDir(options.Dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 3, "temporary files must be removed")
+
+	certs.Cert = []byte("new cert")
+	require.NoError(t, Write(options, certs))
+
+	read, err = Read(options)
+	require.NoError(t, err)
+	require.Equal(t, "new cert", string(read.Cert))
+
+	require.NoError(t, os.Remove(filepath.Join(options.Dir, "tls.key")))
+
+	_, err = Read(options)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrNoFiles)
+}
+
+func TestWriteThenReadCAKeyAndRollover(t *testing.T) {
+	t.Parallel()
+
+	options := DefaultOptions(t.TempDir())
+	since := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	certs := &k8s.Certificates{
+		CA:       []byte("ca"),
+		Cert:     []byte("cert"),
+		Key:      []byte("key"),
+		CAKey:    []byte("ca key"),
+		Rollover: &k8s.Rollover{Phase: k8s.RolloverPhaseCABundled, Since: since},
+	}
+	require.NoError(t, Write(options, certs))
+
+	info, err := os.Stat(filepath.Join(options.Dir, "ca.key"))
+	require.NoError(t, err)
+	require.Equal(t, fs.FileMode(0o600), info.Mode().Perm())
+
+	read, err := Read(options)
+	require.NoError(t, err)
+	require.Equal(t, certs, read)
+
+	// A bundle without ca key and rollover removes the files of the previous one.
+	certs.CAKey = nil
+	certs.Rollover = nil
+	require.NoError(t, Write(options, certs))
+
+	read, err = Read(options)
+	require.NoError(t, err)
+	require.Equal(t, certs, read)
+
+	entries, err := os.ReadDir(options.Dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	require.NoError(t, os.WriteFile(filepath.Join(options.Dir, "ca-rollover.json"), []byte(`{"certgen.io/ca-rollover-phase":"unknown"}`), 0o600))
+
+	_, err = Read(options)
+	require.ErrorContains(t, err, "invalid rollover state")
+}
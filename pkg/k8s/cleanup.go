@@ -0,0 +1,256 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CleanupAction selects what CleanupObjects does with the caBundle of an object.
+type CleanupAction string
+
+const (
+	// CleanupActionClear removes the caBundle.
+	CleanupActionClear CleanupAction = "clear"
+	// CleanupActionRelease keeps the caBundle, but removes the ownership of the kube-webhook-certgen field manager,
+	// so a later server-side apply of another manager, e.g. a reinstall, takes over the caBundle without a conflict.
+	CleanupActionRelease CleanupAction = "release"
+)
+
+// DeleteSecret deletes the secret. A missing secret is not an error.
+func (k *K8s) DeleteSecret(ctx context.Context, secretName, namespace string) error {
+	slog.InfoContext(ctx, "deleting secret",
+		slog.String("secret", secretName),
+		slog.String("namespace", namespace),
+	)
+
+	err := k.clientSet.CoreV1().Secrets(namespace).Delete(ctx, secretName, metav1.DeleteOptions{DryRun: k.dryRun})
+
+	switch {
+	case k8serrors.IsNotFound(err):
+		slog.InfoContext(ctx, "secret does not exist, nothing to delete")
+	case err != nil:
+		return fmt.Errorf("error deleting secret: %w", err)
+	}
+
+	return nil
+}
+
+// CleanupObjects applies action to each object selected by options. Missing objects are skipped.
+// CABundle, FailurePolicyType and PatchMethod of options are ignored.
+func (k *K8s) CleanupObjects(ctx context.Context, options PatchOptions, action CleanupAction) (PatchResult, error) {
+	switch action {
+	case CleanupActionClear, CleanupActionRelease:
+	default:
+		return nil, fmt.Errorf("unknown cleanup action '%s', must be 'clear' or 'release'", action)
+	}
+
+	if err := options.WebhookFilter.Validate(); err != nil {
+		return nil, err
+	}
+
+	var result PatchResult
+
+	for _, name := range options.APIServiceNames {
+		err := k.cleanupAPIService(ctx, name, action)
+		result = append(result, ObjectResult{Kind: "APIService", Name: name, Err: err})
+	}
+
+	for _, name := range options.CustomResourceDefinitionNames {
+		err := k.cleanupCustomResourceDefinition(ctx, name, action)
+		result = append(result, ObjectResult{Kind: "CustomResourceDefinition", Name: name, Err: err})
+	}
+
+	for _, name := range options.ValidatingWebhookConfigurationNames {
+		err := k.cleanupValidatingWebhook(ctx, name, action, options.WebhookFilter)
+		result = append(result, ObjectResult{Kind: "ValidatingWebhookConfiguration", Name: name, Err: err})
+	}
+
+	for _, name := range options.MutatingWebhookConfigurationNames {
+		err := k.cleanupMutatingWebhook(ctx, name, action, options.WebhookFilter)
+		result = append(result, ObjectResult{Kind: "MutatingWebhookConfiguration", Name: name, Err: err})
+	}
+
+	return result, result.Err()
+}
+
+// releaseManagedFields returns entries without those of the kube-webhook-certgen field manager
+// and whether any entry was removed.
+func releaseManagedFields(entries []metav1.ManagedFieldsEntry) ([]metav1.ManagedFieldsEntry, bool) {
+	released := make([]metav1.ManagedFieldsEntry, 0, len(entries))
+
+	for _, entry := range entries {
+		if entry.Manager != "kube-webhook-certgen" {
+			released = append(released, entry)
+		}
+	}
+
+	if len(released) == len(entries) {
+		return entries, false
+	}
+
+	if len(released) == 0 {
+		// An empty list leaves the managed fields unchanged, a list with a single empty entry clears them.
+		released = []metav1.ManagedFieldsEntry{{}}
+	}
+
+	return released, true
+}
+
+// skipMissing logs and drops not found errors, as the object may have been deleted before.
+func skipMissing(ctx context.Context, kind, name string, err error) error {
+	if k8serrors.IsNotFound(err) {
+		slog.InfoContext(ctx, "object does not exist, skipping",
+			slog.String("kind", kind),
+			slog.String("name", name),
+		)
+
+		return nil
+	}
+
+	return err
+}
+
+func (k *K8s) cleanupAPIService(ctx context.Context, name string, action CleanupAction) error {
+	client := k.aggregatorClientSet.ApiregistrationV1().APIServices()
+
+	apiService, err := client.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return skipMissing(ctx, "APIService", name, fmt.Errorf("error getting APIService: %w", err))
+	}
+
+	changed := len(apiService.Spec.CABundle) > 0
+
+	if action == CleanupActionRelease {
+		apiService.ManagedFields, changed = releaseManagedFields(apiService.ManagedFields)
+	} else {
+		apiService.Spec.CABundle = nil
+	}
+
+	if !changed {
+		return nil
+	}
+
+	if _, err = client.Update(ctx, apiService, metav1.UpdateOptions{DryRun: k.dryRun}); err != nil {
+		return fmt.Errorf("error updating APIService: %w", err)
+	}
+
+	slog.InfoContext(ctx, "cleaned up APIService",
+		slog.String("api_service", name),
+		slog.String("action", string(action)),
+	)
+
+	return nil
+}
+
+func (k *K8s) cleanupCustomResourceDefinition(ctx context.Context, name string, action CleanupAction) error {
+	client := k.apiExtensionsClientSet.ApiextensionsV1().CustomResourceDefinitions()
+
+	crd, err := client.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return skipMissing(ctx, "CustomResourceDefinition", name, fmt.Errorf("error getting CustomResourceDefinition: %w", err))
+	}
+
+	clientConfig := crdClientConfig(crd)
+	changed := clientConfig != nil && len(clientConfig.CABundle) > 0
+
+	if action == CleanupActionRelease {
+		crd.ManagedFields, changed = releaseManagedFields(crd.ManagedFields)
+	} else if changed {
+		clientConfig.CABundle = nil
+	}
+
+	if !changed {
+		return nil
+	}
+
+	if _, err = client.Update(ctx, crd, metav1.UpdateOptions{DryRun: k.dryRun}); err != nil {
+		return fmt.Errorf("error updating CustomResourceDefinition: %w", err)
+	}
+
+	slog.InfoContext(ctx, "cleaned up CustomResourceDefinition",
+		slog.String("crd", name),
+		slog.String("action", string(action)),
+	)
+
+	return nil
+}
+
+func (k *K8s) cleanupValidatingWebhook(ctx context.Context, name string, action CleanupAction, filter WebhookFilter) error {
+	client := k.clientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations()
+
+	valHook, err := client.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return skipMissing(ctx, "ValidatingWebhookConfiguration", name, fmt.Errorf("failed getting validating webhook: %w", err))
+	}
+
+	var changed bool
+
+	if action == CleanupActionRelease {
+		valHook.ManagedFields, changed = releaseManagedFields(valHook.ManagedFields)
+	} else {
+		for i := range valHook.Webhooks {
+			h := &valHook.Webhooks[i]
+			if filter.Matches(h.Name, h.ClientConfig) && len(h.ClientConfig.CABundle) > 0 {
+				h.ClientConfig.CABundle = nil
+				changed = true
+			}
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+
+	if _, err = client.Update(ctx, valHook, metav1.UpdateOptions{DryRun: k.dryRun}); err != nil {
+		return fmt.Errorf("failed updating validating webhook: %w", err)
+	}
+
+	slog.InfoContext(ctx, "cleaned up validating webhook configuration",
+		slog.String("configuration_name", name),
+		slog.String("action", string(action)),
+	)
+
+	return nil
+}
+
+func (k *K8s) cleanupMutatingWebhook(ctx context.Context, name string, action CleanupAction, filter WebhookFilter) error {
+	client := k.clientSet.AdmissionregistrationV1().MutatingWebhookConfigurations()
+
+	mutHook, err := client.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return skipMissing(ctx, "MutatingWebhookConfiguration", name, fmt.Errorf("failed getting mutating webhook: %w", err))
+	}
+
+	var changed bool
+
+	if action == CleanupActionRelease {
+		mutHook.ManagedFields, changed = releaseManagedFields(mutHook.ManagedFields)
+	} else {
+		for i := range mutHook.Webhooks {
+			h := &mutHook.Webhooks[i]
+			if filter.Matches(h.Name, h.ClientConfig) && len(h.ClientConfig.CABundle) > 0 {
+				h.ClientConfig.CABundle = nil
+				changed = true
+			}
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+
+	if _, err = client.Update(ctx, mutHook, metav1.UpdateOptions{DryRun: k.dryRun}); err != nil {
+		return fmt.Errorf("failed updating mutating webhook: %w", err)
+	}
+
+	slog.InfoContext(ctx, "cleaned up mutating webhook configuration",
+		slog.String("configuration_name", name),
+		slog.String("action", string(action)),
+	)
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admissionregistration/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	apiregistrationv1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
+	aggregatorfake "k8s.io/kube-aggregator/pkg/client/clientset_generated/clientset/fake"
+)
+
+func newTestK8sForCleanup(ca []byte) *K8s {
+	managedFields := []metav1.ManagedFieldsEntry{
+		{Manager: "kube-webhook-certgen", Operation: metav1.ManagedFieldsOperationApply},
+		{Manager: "helm", Operation: metav1.ManagedFieldsOperationUpdate},
+	}
+
+	crd := newTestCRD(testCRDName, apiextensionsv1.WebhookConverter)
+	crd.Spec.Conversion.Webhook.ClientConfig.CABundle = ca
+	crd.ManagedFields = managedFields[:1]
+
+	return &K8s{
+		clientSet: fake.NewSimpleClientset(
+			&admissionv1.ValidatingWebhookConfiguration{
+				ObjectMeta: metav1.ObjectMeta{Name: testWebhookName, ManagedFields: managedFields},
+				Webhooks: []admissionv1.ValidatingWebhook{
+					{Name: "v1", ClientConfig: admissionv1.WebhookClientConfig{CABundle: ca}},
+					{Name: "other", ClientConfig: admissionv1.WebhookClientConfig{CABundle: ca}},
+				},
+			},
+			&admissionv1.MutatingWebhookConfiguration{
+				ObjectMeta: metav1.ObjectMeta{Name: testWebhookName, ManagedFields: managedFields},
+				Webhooks:   []admissionv1.MutatingWebhook{{Name: "m1", ClientConfig: admissionv1.WebhookClientConfig{CABundle: ca}}},
+			},
+		),
+		aggregatorClientSet: aggregatorfake.NewSimpleClientset(&apiregistrationv1.APIService{
+			ObjectMeta: metav1.ObjectMeta{Name: testAPIServiceName, ManagedFields: managedFields},
+			Spec:       apiregistrationv1.APIServiceSpec{CABundle: ca},
+		}),
+		apiExtensionsClientSet: apiextensionsfake.NewSimpleClientset(crd),
+	}
+}
+
+func TestCleanupObjects(t *testing.T) {
+	t.Parallel()
+
+	ca := []byte("ca")
+	options := PatchOptions{
+		ValidatingWebhookConfigurationNames: []string{testWebhookName, "missing"},
+		MutatingWebhookConfigurationNames:   []string{testWebhookName},
+		APIServiceNames:                     []string{testAPIServiceName},
+		CustomResourceDefinitionNames:       []string{testCRDName},
+		WebhookFilter:                       WebhookFilter{Names: []string{"v1", "m1"}},
+	}
+
+	t.Run("clear", func(t *testing.T) {
+		t.Parallel()
+
+		k := newTestK8sForCleanup(ca)
+		ctx := contextWithDeadline(t)
+
+		result, err := k.CleanupObjects(ctx, options, CleanupActionClear)
+		require.NoError(t, err)
+		require.Len(t, result, 5)
+
+		valHook, err := k.clientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, testWebhookName, metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Nil(t, valHook.Webhooks[0].ClientConfig.CABundle)
+		require.Equal(t, ca, valHook.Webhooks[1].ClientConfig.CABundle)
+
+		mutHook, err := k.clientSet.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, testWebhookName, metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Nil(t, mutHook.Webhooks[0].ClientConfig.CABundle)
+
+		apiService, err := k.aggregatorClientSet.ApiregistrationV1().APIServices().Get(ctx, testAPIServiceName, metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Nil(t, apiService.Spec.CABundle)
+
+		caBundle, err := k.getCustomResourceDefinitionCABundle(ctx, testCRDName)
+		require.NoError(t, err)
+		require.Empty(t, caBundle)
+	})
+
+	t.Run("release", func(t *testing.T) {
+		t.Parallel()
+
+		k := newTestK8sForCleanup(ca)
+		ctx := contextWithDeadline(t)
+
+		_, err := k.CleanupObjects(ctx, options, CleanupActionRelease)
+		require.NoError(t, err)
+
+		valHook, err := k.clientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, testWebhookName, metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, ca, valHook.Webhooks[0].ClientConfig.CABundle)
+		require.Len(t, valHook.ManagedFields, 1)
+		require.Equal(t, "helm", valHook.ManagedFields[0].Manager)
+
+		apiService, err := k.aggregatorClientSet.ApiregistrationV1().APIServices().Get(ctx, testAPIServiceName, metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, ca, apiService.Spec.CABundle)
+		require.Len(t, apiService.ManagedFields, 1)
+
+		crd, err := k.getCustomResourceDefinition(ctx, testCRDName)
+		require.NoError(t, err)
+		require.Equal(t, []metav1.ManagedFieldsEntry{{}}, crd.GetManagedFields())
+	})
+
+	t.Run("unknown_action", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := newTestK8sForCleanup(ca).CleanupObjects(contextWithDeadline(t), options, "foo")
+		require.Error(t, err)
+	})
+}
+
+func TestDeleteSecret(t *testing.T) {
+	t.Parallel()
+
+	k := testK8sWithUnpatchedObjects()
+	ctx := contextWithDeadline(t)
+
+	require.NoError(t, k.DeleteSecret(ctx, testSecretName, testNamespace))
+
+	_, err := k.GetCaFromSecret(ctx, "ca.crt", testSecretName, testNamespace)
+	require.ErrorIs(t, err, ErrNoSecret)
+
+	require.NoError(t, k.DeleteSecret(ctx, testSecretName, testNamespace))
+}
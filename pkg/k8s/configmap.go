@@ -0,0 +1,92 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1apply "k8s.io/client-go/applyconfigurations/core/v1"
+)
+
+// ErrNoConfigMap is returned when a ConfigMap is not found.
+var ErrNoConfigMap = errors.New("no ConfigMap found")
+
+// GetCaFromConfigMap retrieves the ca certificate stored under caName in a ConfigMap.
+func (k *K8s) GetCaFromConfigMap(ctx context.Context, caName, configMapName, namespace string) ([]byte, error) {
+	slog.DebugContext(ctx, "getting CA from ConfigMap",
+		slog.String("configmap", configMapName),
+		slog.String("namespace", namespace),
+	)
+
+	configMap, err := k.clientSet.CoreV1().ConfigMaps(namespace).Get(ctx, configMapName, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, ErrNoConfigMap
+		}
+
+		return nil, fmt.Errorf("error getting ConfigMap: %w", err)
+	}
+
+	ca, ok := configMap.Data[caName]
+	if !ok {
+		return nil, fmt.Errorf("got ConfigMap, but it did not contain a '%s' key", caName)
+	}
+
+	return []byte(ca), nil
+}
+
+// SaveCaToConfigMap saves the ca certificate under each of caNames in a ConfigMap in the specified namespace.
+// The ConfigMap is created or updated with a server-side apply, so other keys of an existing ConfigMap are preserved.
+// Nothing is written if the ConfigMap already holds the ca under all caNames.
+func (k *K8s) SaveCaToConfigMap(ctx context.Context, caNames []string, configMapName, namespace string, ca []byte) error {
+	slog.DebugContext(ctx, "saving CA to ConfigMap",
+		slog.String("configmap", configMapName),
+		slog.String("namespace", namespace),
+	)
+
+	client := k.clientSet.CoreV1().ConfigMaps(namespace)
+
+	data := make(map[string]string, len(caNames))
+	for _, caName := range caNames {
+		data[caName] = string(ca)
+	}
+
+	existing, err := client.Get(ctx, configMapName, metav1.GetOptions{})
+
+	switch {
+	case k8serrors.IsNotFound(err):
+	case err != nil:
+		return fmt.Errorf("error getting ConfigMap: %w", err)
+	case configMapContains(existing, data):
+		slog.DebugContext(ctx, "ConfigMap is up to date")
+
+		return nil
+	}
+
+	if _, err = client.Apply(ctx, corev1apply.ConfigMap(configMapName, namespace).WithData(data), metav1.ApplyOptions{
+		FieldManager: "kube-webhook-certgen",
+		Force:        true,
+		DryRun:       k.dryRun,
+	}); err != nil {
+		return fmt.Errorf("error applying ConfigMap: %w", err)
+	}
+
+	slog.DebugContext(ctx, "successfully saved ConfigMap")
+
+	return nil
+}
+
+// configMapContains reports whether configMap holds all of data.
+func configMapContains(configMap *v1.ConfigMap, data map[string]string) bool {
+	for key, value := range data {
+		if stored, ok := configMap.Data[key]; !ok || stored != value {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,58 @@
+package k8s
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSaveThenLoadConfigMap(t *testing.T) {
+	t.Parallel()
+
+	const testConfigMapName = "webhook-ca"
+
+	k := newTestSimpleK8s(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: testNamespace},
+	})
+	ctx := contextWithDeadline(t)
+
+	_, err := k.GetCaFromConfigMap(ctx, "ca.crt", testConfigMapName, testNamespace)
+	require.ErrorIs(t, err, ErrNoConfigMap)
+
+	require.NoError(t, k.SaveCaToConfigMap(ctx, []string{"ca.crt", "service-ca.crt"}, testConfigMapName, testNamespace, []byte("ca")))
+
+	ca, err := k.GetCaFromConfigMap(ctx, "ca.crt", testConfigMapName, testNamespace)
+	require.NoError(t, err)
+	require.Equal(t, "ca", string(ca))
+
+	configMap, err := k.clientSet.CoreV1().ConfigMaps(testNamespace).Get(ctx, testConfigMapName, metav1.GetOptions{})
+	require.NoError(t, err)
+
+	configMap.Data["unrelated"] = "kept"
+	_, err = k.clientSet.CoreV1().ConfigMaps(testNamespace).Update(ctx, configMap, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, k.SaveCaToConfigMap(ctx, []string{"ca.crt", "service-ca.crt"}, testConfigMapName, testNamespace, []byte("new ca")))
+
+	configMap, err = k.clientSet.CoreV1().ConfigMaps(testNamespace).Get(ctx, testConfigMapName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"ca.crt": "new ca", "service-ca.crt": "new ca", "unrelated": "kept"}, configMap.Data)
+	require.True(t, slices.ContainsFunc(configMap.ManagedFields, func(entry metav1.ManagedFieldsEntry) bool {
+		return entry.Manager == "kube-webhook-certgen" && entry.Operation == metav1.ManagedFieldsOperationApply
+	}), "the ConfigMap must be applied server-side")
+
+	// An up to date ConfigMap is not written again.
+	require.NoError(t, k.SaveCaToConfigMap(ctx, []string{"ca.crt"}, testConfigMapName, testNamespace, []byte("new ca")))
+
+	unchanged, err := k.clientSet.CoreV1().ConfigMaps(testNamespace).Get(ctx, testConfigMapName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, configMap.ResourceVersion, unchanged.ResourceVersion)
+
+	require.NoError(t, k.SaveCaToConfigMap(ctx, []string{"ca.crt"}, "other", testNamespace, []byte("ca")))
+
+	_, err = k.GetCaFromConfigMap(ctx, "missing", testConfigMapName, testNamespace)
+	require.Error(t, err)
+}
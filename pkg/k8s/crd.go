@@ -0,0 +1,121 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsapplyv1 "k8s.io/apiextensions-apiserver/pkg/client/applyconfiguration/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// crdCABundleField is the path of the conversion webhook caBundle in a CustomResourceDefinition.
+const crdCABundleField = "spec.conversion.webhook.clientConfig.caBundle"
+
+// getCustomResourceDefinition returns the CustomResourceDefinition with the given name.
+// It fails if the CustomResourceDefinition does not use a conversion webhook.
+func (k *K8s) getCustomResourceDefinition(ctx context.Context, name string) (*apiextensionsv1.CustomResourceDefinition, error) {
+	crd, err := k.apiExtensionsClientSet.ApiextensionsV1().CustomResourceDefinitions().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error getting CustomResourceDefinition: %w", err)
+	}
+
+	if !usesConversionWebhook(crd) {
+		return nil, fmt.Errorf("CustomResourceDefinition '%s' does not use a conversion webhook", name)
+	}
+
+	return crd, nil
+}
+
+// usesConversionWebhook reports whether crd converts between its versions with a webhook.
+func usesConversionWebhook(crd *apiextensionsv1.CustomResourceDefinition) bool {
+	return crd.Spec.Conversion != nil && crd.Spec.Conversion.Strategy == apiextensionsv1.WebhookConverter
+}
+
+// crdClientConfig returns the client config of the conversion webhook of crd, or nil if it has none.
+func crdClientConfig(crd *apiextensionsv1.CustomResourceDefinition) *apiextensionsv1.WebhookClientConfig {
+	if crd.Spec.Conversion == nil || crd.Spec.Conversion.Webhook == nil {
+		return nil
+	}
+
+	return crd.Spec.Conversion.Webhook.ClientConfig
+}
+
+// getCustomResourceDefinitionCABundle returns the caBundle of the conversion webhook of a CustomResourceDefinition.
+func (k *K8s) getCustomResourceDefinitionCABundle(ctx context.Context, name string) ([]byte, error) {
+	crd, err := k.getCustomResourceDefinition(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if clientConfig := crdClientConfig(crd); clientConfig != nil {
+		return clientConfig.CABundle, nil
+	}
+
+	return nil, nil
+}
+
+// patchCustomResourceDefinition patches the conversion webhook of a CustomResourceDefinition with the specified method (patch or update).
+func (k *K8s) patchCustomResourceDefinition(ctx context.Context, name string, ca []byte, patchMethod string) error {
+	slog.InfoContext(ctx, "patching CustomResourceDefinition",
+		slog.String("crd", name),
+	)
+
+	if patchMethod == "update" {
+		if err := k.updateCustomResourceDefinition(ctx, name, ca); err != nil {
+			return err
+		}
+	}
+
+	return k.applyCustomResourceDefinition(ctx, name, ca)
+}
+
+func (k *K8s) applyCustomResourceDefinition(ctx context.Context, name string, ca []byte) error {
+	if _, err := k.getCustomResourceDefinition(ctx, name); err != nil {
+		return err
+	}
+
+	applyConfig := apiextensionsapplyv1.CustomResourceDefinition(name).
+		WithSpec(apiextensionsapplyv1.CustomResourceDefinitionSpec().
+			WithConversion(apiextensionsapplyv1.CustomResourceConversion().
+				WithWebhook(apiextensionsapplyv1.WebhookConversion().
+					WithClientConfig(apiextensionsapplyv1.WebhookClientConfig().WithCABundle(ca...)))))
+
+	if _, err := k.apiExtensionsClientSet.ApiextensionsV1().CustomResourceDefinitions().Apply(ctx, applyConfig, metav1.ApplyOptions{
+		FieldManager: "kube-webhook-certgen",
+		Force:        true,
+		DryRun:       k.dryRun,
+	}); err != nil {
+		return fmt.Errorf("failed patching CustomResourceDefinition: %w", err)
+	}
+
+	slog.DebugContext(ctx, "successfully applied CustomResourceDefinition")
+
+	return nil
+}
+
+func (k *K8s) updateCustomResourceDefinition(ctx context.Context, name string, ca []byte) error {
+	crd, err := k.getCustomResourceDefinition(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if crd.Spec.Conversion.Webhook == nil {
+		crd.Spec.Conversion.Webhook = &apiextensionsv1.WebhookConversion{}
+	}
+
+	if crd.Spec.Conversion.Webhook.ClientConfig == nil {
+		crd.Spec.Conversion.Webhook.ClientConfig = &apiextensionsv1.WebhookClientConfig{}
+	}
+
+	crd.Spec.Conversion.Webhook.ClientConfig.CABundle = ca
+
+	if _, err = k.apiExtensionsClientSet.ApiextensionsV1().CustomResourceDefinitions().Update(ctx, crd, metav1.UpdateOptions{DryRun: k.dryRun}); err != nil {
+		return fmt.Errorf("failed patching CustomResourceDefinition: %w", err)
+	}
+
+	slog.DebugContext(ctx, "successfully updated CustomResourceDefinition")
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package k8s
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	aggregatorfake "k8s.io/kube-aggregator/pkg/client/clientset_generated/clientset/fake"
+)
+
+const testCRDName = "widgets.example.com"
+
+func newTestCRD(name string, strategy apiextensionsv1.ConversionStrategyType) *apiextensionsv1.CustomResourceDefinition {
+	conversion := &apiextensionsv1.CustomResourceConversion{Strategy: strategy}
+	if strategy == apiextensionsv1.WebhookConverter {
+		conversion.Webhook = &apiextensionsv1.WebhookConversion{
+			ConversionReviewVersions: []string{"v1"},
+			ClientConfig: &apiextensionsv1.WebhookClientConfig{
+				Service: &apiextensionsv1.ServiceReference{Name: "webhook", Namespace: testNamespace},
+			},
+		}
+	}
+
+	return &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group:      "example.com",
+			Conversion: conversion,
+		},
+	}
+}
+
+func newTestK8sWithCRDs(objects ...runtime.Object) *K8s {
+	apiExtensionsClientSet := apiextensionsfake.NewSimpleClientset(objects...)
+
+	// The fake clientset of apiextensions has no schema to handle apply patches with.
+	// Merge them into the existing object instead.
+	apiExtensionsClientSet.PrependReactor("patch", "customresourcedefinitions", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patchAction, ok := action.(k8stesting.PatchAction)
+		if !ok || patchAction.GetPatchType() != types.ApplyPatchType {
+			return false, nil, nil
+		}
+
+		obj, err := apiExtensionsClientSet.Tracker().Get(action.GetResource(), action.GetNamespace(), patchAction.GetName())
+		if err != nil {
+			return true, nil, err
+		}
+
+		existing, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return true, nil, err
+		}
+
+		var patch map[string]any
+		if err = json.Unmarshal(patchAction.GetPatch(), &patch); err != nil {
+			return true, nil, err
+		}
+
+		mergeTestObject(existing, patch)
+
+		crd := &apiextensionsv1.CustomResourceDefinition{}
+		if err = runtime.DefaultUnstructuredConverter.FromUnstructured(existing, crd); err != nil {
+			return true, nil, err
+		}
+
+		return true, crd, apiExtensionsClientSet.Tracker().Update(action.GetResource(), crd, action.GetNamespace())
+	})
+
+	return &K8s{
+		clientSet:              fake.NewSimpleClientset(),
+		aggregatorClientSet:    aggregatorfake.NewSimpleClientset(),
+		apiExtensionsClientSet: apiExtensionsClientSet,
+		dynamicClient:          newTestDynamicClient(),
+	}
+}
+
+func mergeTestObject(dst, src map[string]any) {
+	for key, value := range src {
+		srcMap, srcIsMap := value.(map[string]any)
+		dstMap, dstIsMap := dst[key].(map[string]any)
+
+		if srcIsMap && dstIsMap {
+			mergeTestObject(dstMap, srcMap)
+
+			continue
+		}
+
+		dst[key] = value
+	}
+}
+
+func TestPatchCustomResourceDefinition(t *testing.T) {
+	t.Parallel()
+
+	for _, patchMethod := range []string{"patch", "update"} {
+		t.Run(patchMethod, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := contextWithDeadline(t)
+			k := newTestK8sWithCRDs(newTestCRD(testCRDName, apiextensionsv1.WebhookConverter))
+
+			o := PatchOptions{
+				CustomResourceDefinitionNames: []string{testCRDName},
+				CABundle:                      []byte("foo"),
+				PatchMethod:                   patchMethod,
+			}
+
+			inSync, err := k.CABundleInSync(ctx, o)
+			require.NoError(t, err)
+			require.False(t, inSync)
+
+			_, err = k.PatchObjects(ctx, o)
+			require.NoError(t, err)
+
+			crd, err := k.apiExtensionsClientSet.ApiextensionsV1().CustomResourceDefinitions().Get(ctx, testCRDName, metav1.GetOptions{})
+			require.NoError(t, err)
+			require.Equal(t, []byte("foo"), crd.Spec.Conversion.Webhook.ClientConfig.CABundle)
+			require.Equal(t, "webhook", crd.Spec.Conversion.Webhook.ClientConfig.Service.Name, "unrelated fields must be preserved")
+
+			inSync, err = k.CABundleInSync(ctx, o)
+			require.NoError(t, err)
+			require.True(t, inSync)
+		})
+	}
+}
+
+func TestPatchCustomResourceDefinitionWithoutConversionWebhook(t *testing.T) {
+	t.Parallel()
+
+	ctx := contextWithDeadline(t)
+	k := newTestK8sWithCRDs(newTestCRD(testCRDName, apiextensionsv1.NoneConverter))
+
+	_, err := k.PatchObjects(ctx, PatchOptions{
+		CustomResourceDefinitionNames: []string{testCRDName},
+		CABundle:                      []byte("foo"),
+		PatchMethod:                   "patch",
+	})
+	require.ErrorContains(t, err, "does not use a conversion webhook")
+
+	_, err = k.PatchObjects(ctx, PatchOptions{
+		CustomResourceDefinitionNames: []string{"missing.example.com"},
+		CABundle:                      []byte("foo"),
+		PatchMethod:                   "patch",
+	})
+	require.Error(t, err)
+}
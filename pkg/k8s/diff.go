@@ -0,0 +1,257 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Change is the change of a single field of an object.
+type Change struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// ObjectDiff lists the changes that patching or saving would apply to an object.
+// Certificate material is only represented by its fingerprint.
+type ObjectDiff struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Changes   []Change
+}
+
+// String formats the diff as one line per changed field.
+func (d ObjectDiff) String() string {
+	name := d.Name
+	if d.Namespace != "" {
+		name = d.Namespace + "/" + d.Name
+	}
+
+	if len(d.Changes) == 0 {
+		return fmt.Sprintf("%s %s: no changes\n", d.Kind, name)
+	}
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s %s:\n", d.Kind, name)
+
+	for _, change := range d.Changes {
+		fmt.Fprintf(&b, "  %s: %s -> %s\n", change.Field, change.Old, change.New)
+	}
+
+	return b.String()
+}
+
+// fingerprint returns a short SHA-256 fingerprint of data, or "<none>" if data is empty.
+func fingerprint(data []byte) string {
+	if len(data) == 0 {
+		return "<none>"
+	}
+
+	sum := sha256.Sum256(data)
+
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// diffBytes appends a change to changes if current and desired differ.
+func diffBytes(changes []Change, field string, current, desired []byte) []Change {
+	if bytes.Equal(current, desired) {
+		return changes
+	}
+
+	return append(changes, Change{Field: field, Old: fingerprint(current), New: fingerprint(desired)})
+}
+
+// diffFailurePolicy appends a change to changes if failurePolicy is set and differs from current.
+func diffFailurePolicy(
+	changes []Change,
+	field string,
+	current *admissionregistrationv1.FailurePolicyType,
+	failurePolicy admissionregistrationv1.FailurePolicyType,
+) []Change {
+	if failurePolicy == "" || (current != nil && *current == failurePolicy) {
+		return changes
+	}
+
+	old := "<none>"
+	if current != nil {
+		old = string(*current)
+	}
+
+	return append(changes, Change{Field: field, Old: old, New: string(failurePolicy)})
+}
+
+// DiffObjects returns the changes PatchObjects would apply to each object selected by options, without modifying any object.
+func (k *K8s) DiffObjects(ctx context.Context, options PatchOptions) ([]ObjectDiff, error) {
+	if err := validatePatchOptions(options); err != nil {
+		return nil, err
+	}
+
+	var diffs []ObjectDiff
+
+	for _, name := range options.APIServiceNames {
+		apiService, err := k.aggregatorClientSet.ApiregistrationV1().APIServices().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("error getting APIService: %w", err)
+		}
+
+		diffs = append(diffs, ObjectDiff{
+			Kind:    "APIService",
+			Name:    name,
+			Changes: diffBytes(nil, "spec.caBundle", apiService.Spec.CABundle, options.CABundle),
+		})
+	}
+
+	for _, name := range options.CustomResourceDefinitionNames {
+		caBundle, err := k.getCustomResourceDefinitionCABundle(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+
+		diffs = append(diffs, ObjectDiff{
+			Kind:    "CustomResourceDefinition",
+			Name:    name,
+			Changes: diffBytes(nil, crdCABundleField, caBundle, options.CABundle),
+		})
+	}
+
+	for _, name := range options.ValidatingWebhookConfigurationNames {
+		valHook, err := k.clientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed getting validating webhook: %w", err)
+		}
+
+		diff := ObjectDiff{Kind: "ValidatingWebhookConfiguration", Name: name}
+
+		for i := range valHook.Webhooks {
+			h := &valHook.Webhooks[i]
+			if options.WebhookFilter.Matches(h.Name, h.ClientConfig) {
+				diff.Changes = diffWebhook(diff.Changes, h.Name, h.ClientConfig.CABundle, h.FailurePolicy, options)
+			}
+		}
+
+		diffs = append(diffs, diff)
+	}
+
+	for _, name := range options.MutatingWebhookConfigurationNames {
+		mutHook, err := k.clientSet.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed getting mutating webhook: %w", err)
+		}
+
+		diff := ObjectDiff{Kind: "MutatingWebhookConfiguration", Name: name}
+
+		for i := range mutHook.Webhooks {
+			h := &mutHook.Webhooks[i]
+			if options.WebhookFilter.Matches(h.Name, h.ClientConfig) {
+				diff.Changes = diffWebhook(diff.Changes, h.Name, h.ClientConfig.CABundle, h.FailurePolicy, options)
+			}
+		}
+
+		diffs = append(diffs, diff)
+	}
+
+	return diffs, nil
+}
+
+func diffWebhook(
+	changes []Change,
+	name string,
+	caBundle []byte,
+	failurePolicy *admissionregistrationv1.FailurePolicyType,
+	options PatchOptions,
+) []Change {
+	changes = diffBytes(changes, "webhooks["+name+"].clientConfig.caBundle", caBundle, options.CABundle)
+
+	return diffFailurePolicy(changes, "webhooks["+name+"].failurePolicy", failurePolicy, options.FailurePolicyType)
+}
+
+// DiffSecret returns the changes SaveCertsToSecret would apply to the secret, without modifying it.
+func (k *K8s) DiffSecret(ctx context.Context, secretName, namespace string, keys SecretKeys, certs *Certificates) (ObjectDiff, error) {
+	diff := ObjectDiff{Kind: "Secret", Namespace: namespace, Name: secretName}
+
+	var (
+		data        map[string][]byte
+		annotations map[string]string
+	)
+
+	secret, err := k.clientSet.CoreV1().Secrets(namespace).Get(ctx, secretName, metav1.GetOptions{})
+
+	switch {
+	case k8serrors.IsNotFound(err):
+	case err != nil:
+		return ObjectDiff{}, fmt.Errorf("error getting secret: %w", err)
+	default:
+		data = secret.Data
+		annotations = secret.Annotations
+	}
+
+	desired := secretData(keys, certs)
+
+	// SaveCertsToSecret only applies the desired keys, other keys are kept.
+	names := make([]string, 0, len(desired))
+	for name := range desired {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	for _, name := range names {
+		diff.Changes = diffBytes(diff.Changes, "data["+name+"]", data[name], desired[name])
+	}
+
+	for _, name := range []string{CARolloverPhaseAnnotation, CARolloverSinceAnnotation, CARolloverGracePeriodAnnotation} {
+		desiredValue, ok := RolloverAnnotations(certs.Rollover)[name]
+		if !ok {
+			continue
+		}
+
+		currentValue, ok := annotations[name]
+		if !ok {
+			currentValue = "<none>"
+		}
+
+		if currentValue != desiredValue {
+			diff.Changes = append(diff.Changes, Change{Field: "metadata.annotations[" + name + "]", Old: currentValue, New: desiredValue})
+		}
+	}
+
+	return diff, nil
+}
+
+// DiffConfigMap returns the changes SaveCaToConfigMap would apply to the ConfigMap.
+func (k *K8s) DiffConfigMap(ctx context.Context, caNames []string, configMapName, namespace string, ca []byte) (ObjectDiff, error) {
+	diff := ObjectDiff{Kind: "ConfigMap", Namespace: namespace, Name: configMapName}
+
+	var data map[string]string
+
+	configMap, err := k.clientSet.CoreV1().ConfigMaps(namespace).Get(ctx, configMapName, metav1.GetOptions{})
+
+	switch {
+	case k8serrors.IsNotFound(err):
+	case err != nil:
+		return ObjectDiff{}, fmt.Errorf("error getting ConfigMap: %w", err)
+	default:
+		data = configMap.Data
+	}
+
+	// SaveCaToConfigMap only applies the given keys, other keys are kept.
+	names := slices.Clone(caNames)
+	slices.Sort(names)
+
+	for _, name := range slices.Compact(names) {
+		diff.Changes = diffBytes(diff.Changes, "data["+name+"]", []byte(data[name]), ca)
+	}
+
+	return diff, nil
+}
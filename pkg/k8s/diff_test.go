@@ -0,0 +1,166 @@
+package k8s
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestDiffObjects(t *testing.T) {
+	t.Parallel()
+
+	k := testK8sWithUnpatchedObjects()
+	ctx := contextWithDeadline(t)
+	ca, _, _ := genSecretData()
+	ca = append(ca, 'x')
+
+	diffs, err := k.DiffObjects(ctx, PatchOptions{
+		ValidatingWebhookConfigurationNames: []string{testWebhookName},
+		APIServiceNames:                     []string{testAPIServiceName},
+		WebhookFilter:                       WebhookFilter{Names: []string{"v1"}},
+		FailurePolicyType:                   fail,
+		CABundle:                            ca,
+		PatchMethod:                         "update",
+	})
+	require.NoError(t, err)
+	require.Len(t, diffs, 2)
+
+	require.Equal(t, ObjectDiff{
+		Kind:    "APIService",
+		Name:    testAPIServiceName,
+		Changes: []Change{{Field: "spec.caBundle", Old: "<none>", New: fingerprint(ca)}},
+	}, diffs[0])
+
+	require.Equal(t, ObjectDiff{
+		Kind: "ValidatingWebhookConfiguration",
+		Name: testWebhookName,
+		Changes: []Change{
+			{Field: "webhooks[v1].clientConfig.caBundle", Old: "<none>", New: fingerprint(ca)},
+			{Field: "webhooks[v1].failurePolicy", Old: "<none>", New: string(fail)},
+		},
+	}, diffs[1])
+
+	valHook, err := k.clientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, testWebhookName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Empty(t, valHook.Webhooks[0].ClientConfig.CABundle, "diff must not modify objects")
+
+	_, err = k.PatchObjects(ctx, PatchOptions{
+		ValidatingWebhookConfigurationNames: []string{testWebhookName},
+		FailurePolicyType:                   fail,
+		CABundle:                            ca,
+		PatchMethod:                         "update",
+	})
+	require.NoError(t, err)
+
+	diffs, err = k.DiffObjects(ctx, PatchOptions{
+		ValidatingWebhookConfigurationNames: []string{testWebhookName},
+		FailurePolicyType:                   fail,
+		CABundle:                            ca,
+		PatchMethod:                         "update",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "ValidatingWebhookConfiguration "+testWebhookName+": no changes\n", diffs[0].String())
+}
+
+func TestDiffSecret(t *testing.T) {
+	t.Parallel()
+
+	ca, cert, key := genSecretData()
+
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testSecretName,
+			Namespace: testNamespace,
+		},
+		Type: testSecretType,
+		Data: map[string][]byte{"ca.crt": ca, "tls.crt": cert, "tls.key": key, "extra": []byte("extra")},
+	}
+
+	k := newTestSimpleK8s(secret)
+
+	newCert := append([]byte{}, cert...)
+	newCert = append(newCert, 'x')
+
+	diff, err := k.DiffSecret(contextWithDeadline(t), testSecretName, testNamespace, testSecretKeys, &Certificates{CA: ca, Cert: newCert, Key: key})
+	require.NoError(t, err)
+
+	require.Equal(t, []Change{
+		{Field: "data[tls.crt]", Old: fingerprint(cert), New: fingerprint(newCert)},
+	}, diff.Changes)
+
+	diff, err = newTestSimpleK8s().DiffSecret(contextWithDeadline(t), testSecretName, testNamespace, testSecretKeys, &Certificates{CA: ca, Cert: cert, Key: key})
+	require.NoError(t, err)
+	require.Len(t, diff.Changes, 3)
+}
+
+func TestDiffConfigMap(t *testing.T) {
+	t.Parallel()
+
+	ca, _, _ := genSecretData()
+
+	k := newTestSimpleK8s(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "webhook-ca", Namespace: testNamespace},
+		Data:       map[string]string{"ca.crt": string(ca), "extra": "extra"},
+	})
+
+	diff, err := k.DiffConfigMap(contextWithDeadline(t), []string{"ca.crt", "ca.pem"}, "webhook-ca", testNamespace, ca)
+	require.NoError(t, err)
+
+	require.Equal(t, []Change{
+		{Field: "data[ca.pem]", Old: "<none>", New: fingerprint(ca)},
+	}, diff.Changes)
+
+	diff, err = newTestSimpleK8s().DiffConfigMap(contextWithDeadline(t), []string{"ca.crt"}, "webhook-ca", testNamespace, ca)
+	require.NoError(t, err)
+	require.Len(t, diff.Changes, 1)
+}
+
+func TestServerDryRun(t *testing.T) {
+	t.Parallel()
+
+	k := testK8sWithUnpatchedObjects()
+	k.EnableServerDryRun()
+
+	ctx := contextWithDeadline(t)
+	ca, cert, key := genSecretData()
+
+	require.NoError(t, k.SaveCertsToSecret(ctx, testSecretName, testSecretType, testNamespace, testSecretKeys, SecretMetadata{}, &Certificates{CA: ca, Cert: cert, Key: key}))
+
+	for _, patchMethod := range []string{"update", "patch"} {
+		_, err := k.PatchObjects(ctx, PatchOptions{
+			ValidatingWebhookConfigurationNames: []string{testWebhookName},
+			MutatingWebhookConfigurationNames:   []string{testWebhookName},
+			CABundle:                            ca,
+			PatchMethod:                         patchMethod,
+		})
+		require.NoError(t, err)
+	}
+
+	var writes int
+
+	for _, action := range k.clientSet.(*fake.Clientset).Actions() {
+		var dryRun []string
+
+		switch action := action.(type) {
+		case k8stesting.CreateActionImpl:
+			dryRun = action.CreateOptions.DryRun
+		case k8stesting.UpdateActionImpl:
+			dryRun = action.UpdateOptions.DryRun
+		case k8stesting.PatchActionImpl:
+			dryRun = action.PatchOptions.DryRun
+		default:
+			continue
+		}
+
+		writes++
+
+		require.True(t, slices.Contains(dryRun, metav1.DryRunAll), "%s %s must be a dry run", action.GetVerb(), action.GetResource().Resource)
+	}
+
+	require.NotZero(t, writes)
+}
@@ -0,0 +1,109 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// InjectFromAnnotation marks objects which should carry the ca of a secret. Its value is the secret as <namespace>/<name>.
+const InjectFromAnnotation = "certgen.io/inject-from"
+
+// DiscoveryOptions selects the objects found by DiscoverObjects. At least one of the fields must be set.
+type DiscoveryOptions struct {
+	// LabelSelector restricts discovery to objects with matching labels.
+	LabelSelector string
+	// InjectFrom restricts discovery to objects whose InjectFromAnnotation equals it, in the form <namespace>/<name>.
+	InjectFrom string
+}
+
+// matches reports whether object carries the InjectFromAnnotation requested by options.
+// The label selector is evaluated by the API server.
+func (options DiscoveryOptions) matches(object metav1.Object) bool {
+	return options.InjectFrom == "" || object.GetAnnotations()[InjectFromAnnotation] == options.InjectFrom
+}
+
+// DiscoverObjects lists ValidatingWebhookConfigurations, MutatingWebhookConfigurations, APIServices and
+// CustomResourceDefinitions selected by options and returns their names as PatchOptions.
+// CustomResourceDefinitions which do not use a conversion webhook are skipped.
+func (k *K8s) DiscoverObjects(ctx context.Context, options DiscoveryOptions) (PatchOptions, error) {
+	if options.LabelSelector == "" && options.InjectFrom == "" {
+		return PatchOptions{}, errors.New("discovery requires a label selector or an inject-from annotation value")
+	}
+
+	if _, err := labels.Parse(options.LabelSelector); err != nil {
+		return PatchOptions{}, fmt.Errorf("invalid label selector: %w", err)
+	}
+
+	listOptions := metav1.ListOptions{LabelSelector: options.LabelSelector}
+
+	var discovered PatchOptions
+
+	valHooks, err := k.clientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().List(ctx, listOptions)
+	if err != nil {
+		return PatchOptions{}, fmt.Errorf("failed listing validating webhooks: %w", err)
+	}
+
+	for i := range valHooks.Items {
+		if options.matches(&valHooks.Items[i]) {
+			discovered.ValidatingWebhookConfigurationNames = append(discovered.ValidatingWebhookConfigurationNames, valHooks.Items[i].Name)
+		}
+	}
+
+	mutHooks, err := k.clientSet.AdmissionregistrationV1().MutatingWebhookConfigurations().List(ctx, listOptions)
+	if err != nil {
+		return PatchOptions{}, fmt.Errorf("failed listing mutating webhooks: %w", err)
+	}
+
+	for i := range mutHooks.Items {
+		if options.matches(&mutHooks.Items[i]) {
+			discovered.MutatingWebhookConfigurationNames = append(discovered.MutatingWebhookConfigurationNames, mutHooks.Items[i].Name)
+		}
+	}
+
+	apiServices, err := k.aggregatorClientSet.ApiregistrationV1().APIServices().List(ctx, listOptions)
+	if err != nil {
+		return PatchOptions{}, fmt.Errorf("error listing APIServices: %w", err)
+	}
+
+	for i := range apiServices.Items {
+		if options.matches(&apiServices.Items[i]) {
+			discovered.APIServiceNames = append(discovered.APIServiceNames, apiServices.Items[i].Name)
+		}
+	}
+
+	crds, err := k.apiExtensionsClientSet.ApiextensionsV1().CustomResourceDefinitions().List(ctx, listOptions)
+	if err != nil {
+		return PatchOptions{}, fmt.Errorf("error listing CustomResourceDefinitions: %w", err)
+	}
+
+	for i := range crds.Items {
+		crd := &crds.Items[i]
+		if !options.matches(crd) {
+			continue
+		}
+
+		if !usesConversionWebhook(crd) {
+			slog.DebugContext(ctx, "skipping CustomResourceDefinition without conversion webhook",
+				slog.String("crd", crd.Name),
+			)
+
+			continue
+		}
+
+		discovered.CustomResourceDefinitionNames = append(discovered.CustomResourceDefinitionNames, crd.Name)
+	}
+
+	slog.DebugContext(ctx, "discovered objects",
+		slog.Any("validating_webhook_configurations", discovered.ValidatingWebhookConfigurationNames),
+		slog.Any("mutating_webhook_configurations", discovered.MutatingWebhookConfigurationNames),
+		slog.Any("api_services", discovered.APIServiceNames),
+		slog.Any("crds", discovered.CustomResourceDefinitionNames),
+	)
+
+	return discovered, nil
+}
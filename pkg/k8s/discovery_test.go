@@ -0,0 +1,98 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admissionregistration/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	apiregistrationv1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
+	aggregatorfake "k8s.io/kube-aggregator/pkg/client/clientset_generated/clientset/fake"
+)
+
+func TestDiscoverObjects(t *testing.T) {
+	t.Parallel()
+
+	ctx := contextWithDeadline(t)
+
+	labeled := map[string]string{"app.kubernetes.io/name": "foo"}
+	injectFrom := testNamespace + "/" + testSecretName
+	annotated := map[string]string{InjectFromAnnotation: injectFrom}
+
+	labeledCRD := newTestCRD("labeled.example.com", apiextensionsv1.WebhookConverter)
+	labeledCRD.SetLabels(labeled)
+
+	annotatedCRD := newTestCRD("annotated.example.com", apiextensionsv1.WebhookConverter)
+	annotatedCRD.SetAnnotations(annotated)
+
+	noConversionCRD := newTestCRD("none.example.com", apiextensionsv1.NoneConverter)
+	noConversionCRD.SetLabels(labeled)
+	noConversionCRD.SetAnnotations(annotated)
+
+	k := &K8s{
+		clientSet: fake.NewSimpleClientset(
+			&admissionv1.ValidatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: "labeled", Labels: labeled}},
+			&admissionv1.ValidatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: "annotated", Annotations: annotated}},
+			&admissionv1.ValidatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: "other"}},
+			&admissionv1.MutatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: "labeled", Labels: labeled, Annotations: annotated}},
+			&admissionv1.MutatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{
+				Name: "other-secret", Annotations: map[string]string{InjectFromAnnotation: testNamespace + "/other"},
+			}},
+		),
+		aggregatorClientSet: aggregatorfake.NewSimpleClientset(
+			&apiregistrationv1.APIService{ObjectMeta: metav1.ObjectMeta{Name: "v1.labeled", Labels: labeled}},
+			&apiregistrationv1.APIService{ObjectMeta: metav1.ObjectMeta{Name: "v1.annotated", Annotations: annotated}},
+		),
+		apiExtensionsClientSet: apiextensionsfake.NewSimpleClientset(labeledCRD, annotatedCRD, noConversionCRD),
+	}
+
+	t.Run("by_label_selector", func(t *testing.T) {
+		t.Parallel()
+
+		discovered, err := k.DiscoverObjects(ctx, DiscoveryOptions{LabelSelector: "app.kubernetes.io/name=foo"})
+		require.NoError(t, err)
+		require.Equal(t, []string{"labeled"}, discovered.ValidatingWebhookConfigurationNames)
+		require.Equal(t, []string{"labeled"}, discovered.MutatingWebhookConfigurationNames)
+		require.Equal(t, []string{"v1.labeled"}, discovered.APIServiceNames)
+		require.Equal(t, []string{"labeled.example.com"}, discovered.CustomResourceDefinitionNames)
+	})
+
+	t.Run("by_annotation", func(t *testing.T) {
+		t.Parallel()
+
+		discovered, err := k.DiscoverObjects(ctx, DiscoveryOptions{InjectFrom: injectFrom})
+		require.NoError(t, err)
+		require.Equal(t, []string{"annotated"}, discovered.ValidatingWebhookConfigurationNames)
+		require.Equal(t, []string{"labeled"}, discovered.MutatingWebhookConfigurationNames)
+		require.Equal(t, []string{"v1.annotated"}, discovered.APIServiceNames)
+		require.Equal(t, []string{"annotated.example.com"}, discovered.CustomResourceDefinitionNames)
+	})
+
+	t.Run("by_label_selector_and_annotation", func(t *testing.T) {
+		t.Parallel()
+
+		discovered, err := k.DiscoverObjects(ctx, DiscoveryOptions{LabelSelector: "app.kubernetes.io/name=foo", InjectFrom: injectFrom})
+		require.NoError(t, err)
+		require.Empty(t, discovered.ValidatingWebhookConfigurationNames)
+		require.Equal(t, []string{"labeled"}, discovered.MutatingWebhookConfigurationNames)
+		require.Empty(t, discovered.APIServiceNames)
+		require.Empty(t, discovered.CustomResourceDefinitionNames)
+	})
+
+	t.Run("returns_error_when_nothing_selects_objects", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := k.DiscoverObjects(ctx, DiscoveryOptions{})
+		require.Error(t, err)
+	})
+
+	t.Run("returns_error_when_label_selector_is_invalid", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := k.DiscoverObjects(ctx, DiscoveryOptions{LabelSelector: "foo in (bar"})
+		require.Error(t, err)
+	})
+}
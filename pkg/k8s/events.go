@@ -0,0 +1,103 @@
+package k8s
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// Reasons of the Events recorded on the secret and the patched objects.
+const (
+	// ReasonCertificateIssued is recorded on the secret when new certificates are written to it.
+	ReasonCertificateIssued = "CertificateIssued"
+	// ReasonCABundleInjected is recorded on an object when the ca was patched into it.
+	ReasonCABundleInjected = "CABundleInjected"
+	// ReasonPatchFailed is recorded on an object which failed to patch.
+	ReasonPatchFailed = "PatchFailed"
+	// ReasonCertificateExpiringSoon is recorded on the secret when the stored certificates are renewed because they expire soon.
+	ReasonCertificateExpiringSoon = "CertificateExpiringSoon"
+)
+
+// SetEventRecorder makes all subsequent writes record Events with recorder. By default, no Events are recorded.
+// No Events are recorded in a server-side dry run.
+func (k *K8s) SetEventRecorder(recorder record.EventRecorder) {
+	k.recorder = recorder
+}
+
+// RecordSecretEvent records an Event on the secret.
+func (k *K8s) RecordSecretEvent(ctx context.Context, secretName, namespace, eventType, reason, message string) {
+	if !k.recordsEvents() {
+		return
+	}
+
+	secret, err := k.clientSet.CoreV1().Secrets(namespace).Get(ctx, secretName, metav1.GetOptions{})
+	if err != nil {
+		secret = &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: namespace}}
+	}
+
+	k.recorder.Event(secretReference(secret), eventType, reason, message)
+}
+
+// secretReference returns a reference to secret. Objects returned by the typed client have no kind,
+// so the reference is built by hand.
+func secretReference(secret *v1.Secret) *v1.ObjectReference {
+	return &v1.ObjectReference{APIVersion: "v1", Kind: "Secret", Name: secret.Name, Namespace: secret.Namespace, UID: secret.UID}
+}
+
+// recordPatchEvent records the outcome of patching object with the ca on the object.
+func (k *K8s) recordPatchEvent(ctx context.Context, object ObjectResult, ca []byte) {
+	if !k.recordsEvents() {
+		return
+	}
+
+	ref := k.objectReference(ctx, object.Kind, object.Name)
+
+	if object.Err != nil {
+		k.recorder.Eventf(ref, v1.EventTypeWarning, ReasonPatchFailed, "Failed to inject ca bundle %s: %v", fingerprint(ca), object.Err)
+
+		return
+	}
+
+	k.recorder.Eventf(ref, v1.EventTypeNormal, ReasonCABundleInjected, "Injected ca bundle %s", fingerprint(ca))
+}
+
+// recordsEvents reports whether Events are recorded.
+func (k *K8s) recordsEvents() bool {
+	return k.recorder != nil && len(k.dryRun) == 0
+}
+
+// objectReference returns a reference to the cluster-scoped object of the given kind and name, as patched by PatchObjects.
+// The uid, which kubectl describe needs to find the Events of the object, is only set if the object can be read.
+func (k *K8s) objectReference(ctx context.Context, kind, name string) *v1.ObjectReference {
+	ref := &v1.ObjectReference{Kind: kind, Name: name}
+
+	var (
+		object metav1.Object
+		err    error
+	)
+
+	switch kind {
+	case "APIService":
+		ref.APIVersion = "apiregistration.k8s.io/v1"
+		object, err = k.aggregatorClientSet.ApiregistrationV1().APIServices().Get(ctx, name, metav1.GetOptions{})
+	case "CustomResourceDefinition":
+		ref.APIVersion = "apiextensions.k8s.io/v1"
+		object, err = k.apiExtensionsClientSet.ApiextensionsV1().CustomResourceDefinitions().Get(ctx, name, metav1.GetOptions{})
+	case "ValidatingWebhookConfiguration":
+		ref.APIVersion = "admissionregistration.k8s.io/v1"
+		object, err = k.clientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, name, metav1.GetOptions{})
+	case "MutatingWebhookConfiguration":
+		ref.APIVersion = "admissionregistration.k8s.io/v1"
+		object, err = k.clientSet.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, name, metav1.GetOptions{})
+	default:
+		return ref
+	}
+
+	if err == nil {
+		ref.UID = object.GetUID()
+	}
+
+	return ref
+}
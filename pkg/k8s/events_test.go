@@ -0,0 +1,111 @@
+package k8s
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admissionregistration/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+// recordedEvents returns the Events recorded so far, as "<type> <reason> <message>".
+func recordedEvents(recorder *record.FakeRecorder) []string {
+	var events []string
+
+	for {
+		select {
+		case event := <-recorder.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	t.Parallel()
+
+	t.Run("certificate_issued", func(t *testing.T) {
+		t.Parallel()
+
+		k := newTestSimpleK8s()
+		recorder := record.NewFakeRecorder(10)
+		k.SetEventRecorder(recorder)
+
+		ca, cert, key := genSecretData()
+		certs := &Certificates{CA: ca, Cert: cert, Key: key}
+
+		err := k.SaveCertsToSecret(contextWithDeadline(t), testSecretName, testSecretType, testNamespace, testSecretKeys, SecretMetadata{}, certs)
+		require.NoError(t, err)
+
+		// Saving the same certificates again writes nothing.
+		err = k.SaveCertsToSecret(contextWithDeadline(t), testSecretName, testSecretType, testNamespace, testSecretKeys, SecretMetadata{}, certs)
+		require.NoError(t, err)
+
+		events := recordedEvents(recorder)
+		require.Len(t, events, 1)
+		require.True(t, strings.HasPrefix(events[0], "Normal "+ReasonCertificateIssued+" Issued certificate "+fingerprint(cert)), events[0])
+	})
+
+	t.Run("patched_objects", func(t *testing.T) {
+		t.Parallel()
+
+		k := newTestSimpleK8s(&admissionv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: testWebhookName, UID: "1234"},
+			Webhooks:   []admissionv1.ValidatingWebhook{{Name: "v1"}},
+		})
+		recorder := record.NewFakeRecorder(10)
+		recorder.IncludeObject = true
+		k.SetEventRecorder(recorder)
+
+		ca, _, _ := genSecretData()
+
+		_, err := k.PatchObjects(contextWithDeadline(t), PatchOptions{
+			CABundle:                            ca,
+			ValidatingWebhookConfigurationNames: []string{testWebhookName},
+			MutatingWebhookConfigurationNames:   []string{"missing"},
+			PatchMethod:                         "update",
+		})
+		require.Error(t, err)
+
+		events := recordedEvents(recorder)
+		require.Len(t, events, 2)
+		require.Contains(t, events[0], "Normal "+ReasonCABundleInjected+" Injected ca bundle "+fingerprint(ca))
+		require.Contains(t, events[0], "kind=ValidatingWebhookConfiguration")
+		require.Contains(t, events[1], "Warning "+ReasonPatchFailed+" Failed to inject ca bundle")
+		require.Contains(t, events[1], "kind=MutatingWebhookConfiguration")
+
+		ref := k.objectReference(contextWithDeadline(t), "ValidatingWebhookConfiguration", testWebhookName)
+		require.Equal(t, types.UID("1234"), ref.UID)
+		require.Empty(t, k.objectReference(contextWithDeadline(t), "MutatingWebhookConfiguration", "missing").UID)
+	})
+
+	t.Run("secret_event", func(t *testing.T) {
+		t.Parallel()
+
+		k := newTestSimpleK8s()
+		recorder := record.NewFakeRecorder(10)
+		k.SetEventRecorder(recorder)
+
+		k.RecordSecretEvent(contextWithDeadline(t), testSecretName, testNamespace, v1.EventTypeWarning, ReasonCertificateExpiringSoon, "expires soon")
+
+		require.Equal(t, []string{"Warning " + ReasonCertificateExpiringSoon + " expires soon"}, recordedEvents(recorder))
+	})
+
+	t.Run("server_dry_run", func(t *testing.T) {
+		t.Parallel()
+
+		k := newTestSimpleK8s()
+		recorder := record.NewFakeRecorder(10)
+		k.SetEventRecorder(recorder)
+		k.EnableServerDryRun()
+
+		k.RecordSecretEvent(contextWithDeadline(t), testSecretName, testNamespace, v1.EventTypeWarning, ReasonCertificateExpiringSoon, "expires soon")
+
+		require.Empty(t, recordedEvents(recorder))
+	})
+}
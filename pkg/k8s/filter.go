@@ -0,0 +1,63 @@
+package k8s
+
+import (
+	"errors"
+	"fmt"
+	"path"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+)
+
+// ErrNoWebhookMatched is returned when a webhook configuration has no entry selected by the WebhookFilter.
+var ErrNoWebhookMatched = errors.New("no webhook matched")
+
+// WebhookFilter selects the webhook entries within a webhook configuration that are patched.
+// Entries must match all set fields. The zero value selects all entries.
+type WebhookFilter struct {
+	// Names are glob patterns as accepted by path.Match. An entry is selected if its name matches any of them.
+	Names []string
+	// ServiceName selects entries whose clientConfig.service has this name.
+	ServiceName string
+	// ServiceNamespace selects entries whose clientConfig.service is in this namespace.
+	ServiceNamespace string
+}
+
+// Validate reports malformed name patterns.
+func (f WebhookFilter) Validate() error {
+	for _, pattern := range f.Names {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid webhook name pattern '%s': %w", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// Matches reports whether the webhook entry with the given name and client config is selected by the filter.
+func (f WebhookFilter) Matches(name string, clientConfig admissionregistrationv1.WebhookClientConfig) bool {
+	if len(f.Names) > 0 && !f.matchesName(name) {
+		return false
+	}
+
+	if f.ServiceName == "" && f.ServiceNamespace == "" {
+		return true
+	}
+
+	service := clientConfig.Service
+	if service == nil {
+		return false
+	}
+
+	return (f.ServiceName == "" || service.Name == f.ServiceName) &&
+		(f.ServiceNamespace == "" || service.Namespace == f.ServiceNamespace)
+}
+
+func (f WebhookFilter) matchesName(name string) bool {
+	for _, pattern := range f.Names {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,223 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	admissionapplyv1 "k8s.io/client-go/applyconfigurations/admissionregistration/v1"
+)
+
+func TestWebhookFilter(t *testing.T) {
+	t.Parallel()
+
+	ours := admissionv1.WebhookClientConfig{Service: &admissionv1.ServiceReference{Name: "webhook", Namespace: testNamespace}}
+	theirs := admissionv1.WebhookClientConfig{Service: &admissionv1.ServiceReference{Name: "other", Namespace: "other"}}
+	url := admissionv1.WebhookClientConfig{URL: ptr("https://example.com")}
+
+	for name, tc := range map[string]struct {
+		filter       WebhookFilter
+		webhook      string
+		clientConfig admissionv1.WebhookClientConfig
+		matches      bool
+	}{
+		"empty_filter_matches_everything":      {WebhookFilter{}, "foo.example.com", url, true},
+		"name_glob_matches":                    {WebhookFilter{Names: []string{"*.example.com"}}, "foo.example.com", url, true},
+		"name_glob_does_not_match":             {WebhookFilter{Names: []string{"*.example.org"}}, "foo.example.com", url, false},
+		"any_name_glob_matches":                {WebhookFilter{Names: []string{"bar.*", "foo.*"}}, "foo.example.com", url, true},
+		"service_matches":                      {WebhookFilter{ServiceName: "webhook", ServiceNamespace: testNamespace}, "foo", ours, true},
+		"service_name_does_not_match":          {WebhookFilter{ServiceName: "webhook"}, "foo", theirs, false},
+		"service_namespace_does_not_match":     {WebhookFilter{ServiceNamespace: testNamespace}, "foo", theirs, false},
+		"service_filter_does_not_match_url":    {WebhookFilter{ServiceName: "webhook"}, "foo", url, false},
+		"name_and_service_must_both_match":     {WebhookFilter{Names: []string{"bar"}, ServiceName: "webhook"}, "foo", ours, false},
+		"name_and_service_match":               {WebhookFilter{Names: []string{"f*"}, ServiceName: "webhook"}, "foo", ours, true},
+		"service_namespace_alone_matches_ours": {WebhookFilter{ServiceNamespace: testNamespace}, "foo", ours, true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.matches, tc.filter.Matches(tc.webhook, tc.clientConfig))
+		})
+	}
+
+	require.Error(t, WebhookFilter{Names: []string{"[foo"}}.Validate())
+	require.NoError(t, WebhookFilter{Names: []string{"foo*"}}.Validate())
+}
+
+func TestPatchObjectsWithWebhookFilter(t *testing.T) {
+	t.Parallel()
+
+	foreignCA := []byte("foreign")
+	filter := WebhookFilter{ServiceName: "webhook", ServiceNamespace: testNamespace}
+
+	for _, patchMethod := range []string{"patch", "update"} {
+		t.Run(patchMethod, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := contextWithDeadline(t)
+
+			k := newTestSimpleK8s(
+				&admissionv1.ValidatingWebhookConfiguration{
+					ObjectMeta: metav1.ObjectMeta{Name: testWebhookName},
+					Webhooks: []admissionv1.ValidatingWebhook{
+						{Name: "ours", ClientConfig: admissionv1.WebhookClientConfig{
+							Service: &admissionv1.ServiceReference{Name: "webhook", Namespace: testNamespace},
+						}},
+						{Name: "theirs", ClientConfig: admissionv1.WebhookClientConfig{
+							Service:  &admissionv1.ServiceReference{Name: "other", Namespace: "other"},
+							CABundle: foreignCA,
+						}},
+					},
+				},
+			)
+
+			o := PatchOptions{
+				ValidatingWebhookConfigurationNames: []string{testWebhookName},
+				CABundle:                            []byte("foo"),
+				FailurePolicyType:                   fail,
+				PatchMethod:                         patchMethod,
+				WebhookFilter:                       filter,
+			}
+
+			_, err := k.PatchObjects(ctx, o)
+			require.NoError(t, err)
+
+			valHook, err := k.clientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, testWebhookName, metav1.GetOptions{})
+			require.NoError(t, err)
+
+			require.Equal(t, []byte("foo"), valHook.Webhooks[0].ClientConfig.CABundle)
+			require.Equal(t, &fail, valHook.Webhooks[0].FailurePolicy)
+			require.Equal(t, foreignCA, valHook.Webhooks[1].ClientConfig.CABundle)
+			require.Nil(t, valHook.Webhooks[1].FailurePolicy)
+
+			inSync, err := k.CABundleInSync(ctx, o)
+			require.NoError(t, err)
+			require.True(t, inSync, "webhooks not selected by the filter must be ignored")
+		})
+	}
+}
+
+func TestApplyWithWebhookFilterKeepsExcludedCABundle(t *testing.T) {
+	t.Parallel()
+
+	ctx := contextWithDeadline(t)
+	ignore := admissionv1.Ignore
+
+	k := newTestSimpleK8s(
+		&admissionv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: testWebhookName},
+			Webhooks: []admissionv1.ValidatingWebhook{
+				{Name: "ours", ClientConfig: admissionv1.WebhookClientConfig{
+					Service: &admissionv1.ServiceReference{Name: "webhook", Namespace: testNamespace},
+				}},
+				{Name: "theirs", ClientConfig: admissionv1.WebhookClientConfig{
+					Service: &admissionv1.ServiceReference{Name: "other", Namespace: "other"},
+				}},
+			},
+		},
+		&admissionv1.MutatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: testWebhookName},
+			Webhooks: []admissionv1.MutatingWebhook{
+				{Name: "ours", ClientConfig: admissionv1.WebhookClientConfig{
+					Service: &admissionv1.ServiceReference{Name: "webhook", Namespace: testNamespace},
+				}},
+				{Name: "theirs", ClientConfig: admissionv1.WebhookClientConfig{
+					Service: &admissionv1.ServiceReference{Name: "other", Namespace: "other"},
+				}},
+			},
+		},
+	)
+
+	o := PatchOptions{
+		ValidatingWebhookConfigurationNames: []string{testWebhookName},
+		MutatingWebhookConfigurationNames:   []string{testWebhookName},
+		CABundle:                            []byte("unfiltered"),
+		FailurePolicyType:                   ignore,
+		PatchMethod:                         "patch",
+	}
+
+	// An unfiltered run owns the caBundle of every entry.
+	_, err := k.PatchObjects(ctx, o)
+	require.NoError(t, err)
+
+	o.CABundle = []byte("filtered")
+	o.FailurePolicyType = ""
+	o.WebhookFilter = WebhookFilter{ServiceName: "webhook", ServiceNamespace: testNamespace}
+
+	_, err = k.PatchObjects(ctx, o)
+	require.NoError(t, err)
+
+	valHook, err := k.clientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, testWebhookName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []byte("filtered"), valHook.Webhooks[0].ClientConfig.CABundle)
+	require.Equal(t, []byte("unfiltered"), valHook.Webhooks[1].ClientConfig.CABundle)
+	require.Equal(t, &ignore, valHook.Webhooks[1].FailurePolicy)
+
+	mutHook, err := k.clientSet.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, testWebhookName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []byte("filtered"), mutHook.Webhooks[0].ClientConfig.CABundle)
+	require.Equal(t, []byte("unfiltered"), mutHook.Webhooks[1].ClientConfig.CABundle)
+	require.Equal(t, &ignore, mutHook.Webhooks[1].FailurePolicy)
+}
+
+func TestApplyWithWebhookFilterLeavesForeignCABundle(t *testing.T) {
+	t.Parallel()
+
+	ctx := contextWithDeadline(t)
+
+	k := newTestSimpleK8s(
+		&admissionv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: testWebhookName},
+			Webhooks: []admissionv1.ValidatingWebhook{
+				{Name: "ours", ClientConfig: admissionv1.WebhookClientConfig{
+					Service: &admissionv1.ServiceReference{Name: "webhook", Namespace: testNamespace},
+				}},
+				{Name: "theirs", ClientConfig: admissionv1.WebhookClientConfig{
+					Service: &admissionv1.ServiceReference{Name: "other", Namespace: "other"},
+				}},
+			},
+		},
+	)
+
+	// Another field manager owns the caBundle of the excluded entry.
+	_, err := k.clientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Apply(ctx,
+		admissionapplyv1.ValidatingWebhookConfiguration(testWebhookName).WithWebhooks(
+			admissionapplyv1.ValidatingWebhook().WithName("theirs").WithClientConfig(
+				admissionapplyv1.WebhookClientConfig().WithCABundle([]byte("foreign")...),
+			),
+		),
+		metav1.ApplyOptions{FieldManager: "other"},
+	)
+	require.NoError(t, err)
+
+	_, err = k.PatchObjects(ctx, PatchOptions{
+		ValidatingWebhookConfigurationNames: []string{testWebhookName},
+		CABundle:                            []byte("filtered"),
+		PatchMethod:                         "patch",
+		WebhookFilter:                       WebhookFilter{ServiceName: "webhook", ServiceNamespace: testNamespace},
+	})
+	require.NoError(t, err)
+
+	valHook, err := k.clientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, testWebhookName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []byte("filtered"), valHook.Webhooks[0].ClientConfig.CABundle)
+	require.Equal(t, []byte("foreign"), valHook.Webhooks[1].ClientConfig.CABundle)
+
+	for manager, caBundles := range map[string]map[string][]byte{
+		"kube-webhook-certgen": {"ours": []byte("filtered"), "theirs": nil},
+		"other":                {"theirs": []byte("foreign")},
+	} {
+		owned, err := admissionapplyv1.ExtractValidatingWebhookConfiguration(valHook, manager)
+		require.NoError(t, err)
+
+		for _, webhook := range owned.Webhooks {
+			var caBundle []byte
+			if webhook.ClientConfig != nil {
+				caBundle = webhook.ClientConfig.CABundle
+			}
+
+			require.Equal(t, caBundles[*webhook.Name], caBundle, "caBundle of %s owned by %s", *webhook.Name, manager)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apiregistrationv1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
+)
+
+// CABundleStatus reports whether a single caBundle field carries the expected ca.
+type CABundleStatus struct {
+	Kind string
+	Name string
+	// Webhook is the name of the webhook entry within a webhook configuration. It is empty for other kinds.
+	Webhook string
+	// Field is the path of the caBundle field within the object.
+	Field string
+	// InSync reports whether patching the object with the options would leave it unchanged.
+	InSync bool
+	// Err is set if the object could not be read or, for a webhook configuration, none of its entries is selected.
+	// InSync is false in this case.
+	Err error
+}
+
+// InspectCABundles compares the caBundle of each object selected by options with options.CABundle, without modifying any object.
+// Objects that cannot be read are reported with Err set instead of failing the whole inspection.
+// Webhook entries that are not selected by options.WebhookFilter are skipped. A webhook configuration without selected entries
+// is reported with ErrNoWebhookMatched.
+func (k *K8s) InspectCABundles(ctx context.Context, options PatchOptions) ([]CABundleStatus, error) {
+	if err := options.WebhookFilter.Validate(); err != nil {
+		return nil, err
+	}
+
+	var statuses []CABundleStatus
+
+	for _, name := range options.APIServiceNames {
+		status := CABundleStatus{Kind: "APIService", Name: name, Field: "spec.caBundle"}
+
+		apiService, err := k.aggregatorClientSet.ApiregistrationV1().APIServices().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			status.Err = fmt.Errorf("error getting APIService: %w", err)
+		} else {
+			status.InSync = apiServiceInSync(apiService, options.CABundle)
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	for _, name := range options.CustomResourceDefinitionNames {
+		status := CABundleStatus{Kind: "CustomResourceDefinition", Name: name, Field: crdCABundleField}
+
+		caBundle, err := k.getCustomResourceDefinitionCABundle(ctx, name)
+		if err != nil {
+			status.Err = err
+		} else {
+			status.InSync = bytes.Equal(caBundle, options.CABundle)
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	for _, name := range options.ValidatingWebhookConfigurationNames {
+		valHook, err := k.clientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			statuses = append(statuses, CABundleStatus{
+				Kind: "ValidatingWebhookConfiguration",
+				Name: name,
+				Err:  fmt.Errorf("failed getting validating webhook: %w", err),
+			})
+
+			continue
+		}
+
+		matched := false
+
+		for i := range valHook.Webhooks {
+			h := &valHook.Webhooks[i]
+			if options.WebhookFilter.Matches(h.Name, h.ClientConfig) {
+				matched = true
+				statuses = append(statuses, webhookCABundleStatus("ValidatingWebhookConfiguration", name, h.Name, h.ClientConfig, h.FailurePolicy, options))
+			}
+		}
+
+		if !matched {
+			statuses = append(statuses, CABundleStatus{Kind: "ValidatingWebhookConfiguration", Name: name, Err: ErrNoWebhookMatched})
+		}
+	}
+
+	for _, name := range options.MutatingWebhookConfigurationNames {
+		mutHook, err := k.clientSet.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			statuses = append(statuses, CABundleStatus{
+				Kind: "MutatingWebhookConfiguration",
+				Name: name,
+				Err:  fmt.Errorf("failed getting mutating webhook: %w", err),
+			})
+
+			continue
+		}
+
+		matched := false
+
+		for i := range mutHook.Webhooks {
+			h := &mutHook.Webhooks[i]
+			if options.WebhookFilter.Matches(h.Name, h.ClientConfig) {
+				matched = true
+				statuses = append(statuses, webhookCABundleStatus("MutatingWebhookConfiguration", name, h.Name, h.ClientConfig, h.FailurePolicy, options))
+			}
+		}
+
+		if !matched {
+			statuses = append(statuses, CABundleStatus{Kind: "MutatingWebhookConfiguration", Name: name, Err: ErrNoWebhookMatched})
+		}
+	}
+
+	return statuses, nil
+}
+
+// apiServiceInSync reports whether the APIService carries ca. Like patchAPIService, it expects insecureSkipTLSVerify to be disabled.
+func apiServiceInSync(apiService *apiregistrationv1.APIService, ca []byte) bool {
+	return bytes.Equal(apiService.Spec.CABundle, ca) && !apiService.Spec.InsecureSkipTLSVerify
+}
+
+func webhookCABundleStatus(
+	kind, name, webhook string,
+	clientConfig admissionregistrationv1.WebhookClientConfig,
+	failurePolicy *admissionregistrationv1.FailurePolicyType,
+	options PatchOptions,
+) CABundleStatus {
+	return CABundleStatus{
+		Kind:    kind,
+		Name:    name,
+		Webhook: webhook,
+		Field:   "webhooks[" + webhook + "].clientConfig.caBundle",
+		InSync:  webhookInSync(clientConfig, failurePolicy, options),
+	}
+}
+
+// webhookInSync reports whether the webhook entry carries the ca and, if set, the failure policy of options.
+func webhookInSync(
+	clientConfig admissionregistrationv1.WebhookClientConfig,
+	failurePolicy *admissionregistrationv1.FailurePolicyType,
+	options PatchOptions,
+) bool {
+	if !bytes.Equal(clientConfig.CABundle, options.CABundle) {
+		return false
+	}
+
+	return options.FailurePolicyType == "" || (failurePolicy != nil && *failurePolicy == options.FailurePolicyType)
+}
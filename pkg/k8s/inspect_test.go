@@ -0,0 +1,91 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apiregistrationv1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
+	aggregatorfake "k8s.io/kube-aggregator/pkg/client/clientset_generated/clientset/fake"
+)
+
+func TestInspectCABundles(t *testing.T) {
+	t.Parallel()
+
+	k := testK8sWithUnpatchedObjects()
+	k.apiExtensionsClientSet = newTestK8sWithCRDs(newTestCRD(testCRDName, apiextensionsv1.WebhookConverter)).apiExtensionsClientSet
+
+	ctx := contextWithDeadline(t)
+	ca, _, _ := genSecretData()
+	ca = append(ca, 'x')
+
+	_, err := k.PatchObjects(ctx, PatchOptions{
+		ValidatingWebhookConfigurationNames: []string{testWebhookName},
+		CustomResourceDefinitionNames:       []string{testCRDName},
+		WebhookFilter:                       WebhookFilter{Names: []string{"v1"}},
+		CABundle:                            ca,
+		PatchMethod:                         "update",
+	})
+	require.NoError(t, err)
+
+	statuses, err := k.InspectCABundles(ctx, PatchOptions{
+		ValidatingWebhookConfigurationNames: []string{testWebhookName},
+		MutatingWebhookConfigurationNames:   []string{"missing"},
+		APIServiceNames:                     []string{testAPIServiceName},
+		CustomResourceDefinitionNames:       []string{testCRDName},
+		CABundle:                            ca,
+	})
+	require.NoError(t, err)
+	require.Len(t, statuses, 5)
+
+	require.Equal(t, CABundleStatus{Kind: "APIService", Name: testAPIServiceName, Field: "spec.caBundle"}, statuses[0])
+	require.Equal(t, CABundleStatus{
+		Kind:   "CustomResourceDefinition",
+		Name:   testCRDName,
+		Field:  "spec.conversion.webhook.clientConfig.caBundle",
+		InSync: true,
+	}, statuses[1])
+	require.Equal(t, CABundleStatus{
+		Kind:    "ValidatingWebhookConfiguration",
+		Name:    testWebhookName,
+		Webhook: "v1",
+		Field:   "webhooks[v1].clientConfig.caBundle",
+		InSync:  true,
+	}, statuses[2])
+	require.False(t, statuses[3].InSync)
+	require.Equal(t, "v2", statuses[3].Webhook)
+	require.Equal(t, "missing", statuses[4].Name)
+	require.Error(t, statuses[4].Err)
+}
+
+func TestInspectAPIServiceSkippingTLSVerify(t *testing.T) {
+	t.Parallel()
+
+	ctx := contextWithDeadline(t)
+	ca, _, _ := genSecretData()
+
+	k := newTestSimpleK8s()
+	k.aggregatorClientSet = aggregatorfake.NewSimpleClientset(&apiregistrationv1.APIService{
+		ObjectMeta: metav1.ObjectMeta{Name: testAPIServiceName},
+		Spec:       apiregistrationv1.APIServiceSpec{CABundle: ca, InsecureSkipTLSVerify: true},
+	})
+
+	o := PatchOptions{APIServiceNames: []string{testAPIServiceName}, CABundle: ca, PatchMethod: "update"}
+
+	statuses, err := k.InspectCABundles(ctx, o)
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	require.False(t, statuses[0].InSync, "patching disables insecureSkipTLSVerify")
+
+	inSync, err := k.CABundleInSync(ctx, o)
+	require.NoError(t, err)
+	require.False(t, inSync)
+
+	_, err = k.PatchObjects(ctx, o)
+	require.NoError(t, err)
+
+	inSync, err = k.CABundleInSync(ctx, o)
+	require.NoError(t, err)
+	require.True(t, inSync)
+}
@@ -1,29 +1,47 @@
 package k8s
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	v1 "k8s.io/api/core/v1"
+	apiextensionsclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	admissionapplyv1 "k8s.io/client-go/applyconfigurations/admissionregistration/v1"
+	corev1apply "k8s.io/client-go/applyconfigurations/core/v1"
 	meta "k8s.io/client-go/applyconfigurations/meta/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/kube-aggregator/pkg/client/clientset_generated/clientset"
 )
 
 // K8s provides methods to interact with Kubernetes resources for certificate management.
 type K8s struct {
-	clientSet           kubernetes.Interface
-	aggregatorClientSet clientset.Interface
+	clientSet              kubernetes.Interface
+	aggregatorClientSet    clientset.Interface
+	apiExtensionsClientSet apiextensionsclientset.Interface
+	// dynamicClient reads the owner of the secret, which may be of any kind. See SecretMetadata.Owner.
+	dynamicClient dynamic.Interface
+	// dryRun is passed to all write requests. See EnableServerDryRun.
+	dryRun []string
+	// recorder records Events on the written objects. See SetEventRecorder.
+	recorder record.EventRecorder
 }
 
 // New creates a new K8s instance with the provided client sets.
-func New(clientSet kubernetes.Interface, aggregatorClientSet clientset.Interface) (*K8s, error) {
+func New(
+	clientSet kubernetes.Interface,
+	aggregatorClientSet clientset.Interface,
+	apiExtensionsClientSet apiextensionsclientset.Interface,
+	dynamicClient dynamic.Interface,
+) (*K8s, error) {
 	if clientSet == nil {
 		return nil, errors.New("no kubernetes client given")
 	}
@@ -32,25 +50,71 @@ func New(clientSet kubernetes.Interface, aggregatorClientSet clientset.Interface
 		return nil, errors.New("no kubernetes aggregator client given")
 	}
 
+	if apiExtensionsClientSet == nil {
+		return nil, errors.New("no kubernetes apiextensions client given")
+	}
+
+	if dynamicClient == nil {
+		return nil, errors.New("no kubernetes dynamic client given")
+	}
+
 	return &K8s{
-		clientSet:           clientSet,
-		aggregatorClientSet: aggregatorClientSet,
+		clientSet:              clientSet,
+		aggregatorClientSet:    aggregatorClientSet,
+		apiExtensionsClientSet: apiExtensionsClientSet,
+		dynamicClient:          dynamicClient,
 	}, nil
 }
 
+// EnableServerDryRun turns all subsequent write requests into server-side dry runs.
+// The API server validates and admits them, but does not persist any change.
+func (k *K8s) EnableServerDryRun() {
+	k.dryRun = []string{metav1.DryRunAll}
+}
+
 // PatchOptions contains configuration for patching webhook configurations and API services.
 type PatchOptions struct {
-	ValidatingWebhookConfigurationName string
-	MutatingWebhookConfigurationName   string
-	APIServiceName                     string
-	PatchMethod                        string // Either "patch" or "update"
-	FailurePolicyType                  admissionregistrationv1.FailurePolicyType
-	CABundle                           []byte
+	ValidatingWebhookConfigurationNames []string
+	MutatingWebhookConfigurationNames   []string
+	APIServiceNames                     []string
+	CustomResourceDefinitionNames       []string
+	PatchMethod                         string // Either "patch" or "update"
+	FailurePolicyType                   admissionregistrationv1.FailurePolicyType
+	// WebhookFilter selects the webhook entries of the webhook configurations to patch. By default, all entries are patched.
+	WebhookFilter WebhookFilter
+	CABundle      []byte
+}
+
+// ObjectResult is the outcome of patching a single object.
+type ObjectResult struct {
+	Kind string
+	Name string
+	// Err is nil if the object was patched successfully.
+	Err error
+}
+
+// PatchResult holds the outcome of patching each object selected by PatchOptions.
+type PatchResult []ObjectResult
+
+// Err returns the errors of all objects which failed to patch, or nil if all objects were patched.
+func (r PatchResult) Err() error {
+	var errs []error
+
+	for _, object := range r {
+		if object.Err != nil {
+			errs = append(errs, fmt.Errorf("%s '%s': %w", object.Kind, object.Name, object.Err))
+		}
+	}
+
+	return errors.Join(errs...)
 }
 
 // ErrNoSecret is returned when a secret is not found.
 var ErrNoSecret = errors.New("no secret found")
 
+// ErrNoCertificates is returned when a secret exists but holds none of the ca, cert and key, e.g. because it was created empty.
+var ErrNoCertificates = errors.New("secret holds no certificates")
+
 // GetCaFromSecret retrieves the CA certificate from a Kubernetes secret.
 // Returns ErrNoSecret if the secret doesn't exist, or an error if the secret
 // exists but doesn't contain a 'ca.crt' key.
@@ -82,41 +146,174 @@ func (k *K8s) GetCaFromSecret(ctx context.Context, caName, secretName, namespace
 	return data, nil
 }
 
-// PatchObjects patches webhook configurations and/or API services with the provided CA bundle.
-// It validates the patch options and then patches the specified resources.
-func (k *K8s) PatchObjects(ctx context.Context, options PatchOptions) error {
-	if err := validatePatchOptions(options); err != nil {
-		return err
+// SecretKeys are the names of the keys in a secret that hold the certificate material.
+type SecretKeys struct {
+	CA   string
+	Cert string
+	Key  string
+	// CAKey is optional. If empty, the ca key is neither read nor written.
+	CAKey string
+}
+
+// Certificates holds PEM encoded certificate material.
+type Certificates struct {
+	CA    []byte
+	Cert  []byte
+	Key   []byte
+	CAKey []byte
+	// Rollover is the state of an overlapping ca rollover, or nil if none was started.
+	// It is stored in annotations of the secret.
+	Rollover *Rollover
+}
+
+// GetCertsFromSecret retrieves the CA, certificate and key from a Kubernetes secret.
+// Returns ErrNoSecret if the secret doesn't exist, ErrNoCertificates if it holds none of the ca, cert and key,
+// or an error if the secret misses some of them. A missing ca key is not an error.
+func (k *K8s) GetCertsFromSecret(ctx context.Context, secretName, namespace string, keys SecretKeys) (*Certificates, error) {
+	slog.DebugContext(ctx, "getting certificates from secret",
+		slog.String("secret", secretName),
+		slog.String("namespace", namespace),
+	)
+
+	secret, err := k.clientSet.CoreV1().Secrets(namespace).Get(ctx, secretName, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, ErrNoSecret
+		}
+
+		return nil, fmt.Errorf("error getting secret: %w", err)
 	}
 
-	// Patch API service if specified
-	if options.APIServiceName != "" {
-		if err := k.patchAPIService(ctx, options.APIServiceName, options.CABundle); err != nil {
-			return err
+	certs := &Certificates{
+		CA:   secret.Data[keys.CA],
+		Cert: secret.Data[keys.Cert],
+		Key:  secret.Data[keys.Key],
+	}
+
+	if len(certs.CA) == 0 {
+		// Fallback to 'ca' for backward compatibility
+		certs.CA = secret.Data["ca"]
+	}
+
+	if len(certs.CA) == 0 && len(certs.Cert) == 0 && len(certs.Key) == 0 {
+		return nil, ErrNoCertificates
+	}
+
+	if len(certs.CA) == 0 {
+		return nil, fmt.Errorf("got secret, but it did not contain a '%s' key", keys.CA)
+	}
+
+	if len(certs.Cert) == 0 {
+		return nil, fmt.Errorf("got secret, but it did not contain a '%s' key", keys.Cert)
+	}
+
+	if len(certs.Key) == 0 {
+		return nil, fmt.Errorf("got secret, but it did not contain a '%s' key", keys.Key)
+	}
+
+	if keys.CAKey != "" {
+		certs.CAKey = secret.Data[keys.CAKey]
+	}
+
+	if certs.Rollover, err = ParseRollover(secret.Annotations); err != nil {
+		return nil, fmt.Errorf("got secret, but %w", err)
+	}
+
+	return certs, nil
+}
+
+// GetKeyPairFromSecret retrieves a certificate and its key from a Kubernetes secret.
+func (k *K8s) GetKeyPairFromSecret(ctx context.Context, secretName, namespace, certName, keyName string) ([]byte, []byte, error) {
+	slog.DebugContext(ctx, "getting key pair from secret",
+		slog.String("secret", secretName),
+		slog.String("namespace", namespace),
+	)
+
+	secret, err := k.clientSet.CoreV1().Secrets(namespace).Get(ctx, secretName, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, nil, ErrNoSecret
 		}
+
+		return nil, nil, fmt.Errorf("error getting secret: %w", err)
+	}
+
+	cert := secret.Data[certName]
+	if cert == nil {
+		return nil, nil, fmt.Errorf("got secret, but it did not contain a '%s' key", certName)
+	}
+
+	key := secret.Data[keyName]
+	if key == nil {
+		return nil, nil, fmt.Errorf("got secret, but it did not contain a '%s' key", keyName)
+	}
+
+	return cert, key, nil
+}
+
+// PatchObjects patches webhook configurations, API services and/or CRD conversion webhooks with the provided CA bundle.
+// It validates the patch options and then patches the specified resources. A failure to patch one object does not stop
+// the others from being patched; the outcome for each object is reported in the returned PatchResult.
+func (k *K8s) PatchObjects(ctx context.Context, options PatchOptions) (PatchResult, error) {
+	if err := validatePatchOptions(options); err != nil {
+		return nil, err
 	}
 
-	// Patch webhook configurations if specified
-	shouldPatchWebhooks := options.MutatingWebhookConfigurationName != "" ||
-		options.ValidatingWebhookConfigurationName != ""
+	result := make(PatchResult, 0, len(options.APIServiceNames)+len(options.CustomResourceDefinitionNames)+
+		len(options.ValidatingWebhookConfigurationNames)+len(options.MutatingWebhookConfigurationNames))
 
-	if shouldPatchWebhooks {
-		webhookName := getWebhookName(options)
-		patchMutating := options.MutatingWebhookConfigurationName != ""
-		patchValidating := options.ValidatingWebhookConfigurationName != ""
+	for _, name := range options.APIServiceNames {
+		err := k.patchAPIService(ctx, name, options.CABundle)
+		result = append(result, ObjectResult{Kind: "APIService", Name: name, Err: err})
+	}
 
-		return k.patchWebhookConfigurations(
-			ctx,
-			webhookName,
-			options.CABundle,
-			options.FailurePolicyType,
-			patchMutating,
-			patchValidating,
-			options.PatchMethod,
-		)
+	for _, name := range options.CustomResourceDefinitionNames {
+		err := k.patchCustomResourceDefinition(ctx, name, options.CABundle, options.PatchMethod)
+		result = append(result, ObjectResult{Kind: "CustomResourceDefinition", Name: name, Err: err})
 	}
 
-	return nil
+	for _, name := range options.ValidatingWebhookConfigurationNames {
+		err := k.patchValidatingWebhook(ctx, name, options.CABundle, options.FailurePolicyType, options.WebhookFilter, options.PatchMethod)
+		result = append(result, ObjectResult{Kind: "ValidatingWebhookConfiguration", Name: name, Err: err})
+	}
+
+	for _, name := range options.MutatingWebhookConfigurationNames {
+		err := k.patchMutatingWebhook(ctx, name, options.CABundle, options.FailurePolicyType, options.WebhookFilter, options.PatchMethod)
+		result = append(result, ObjectResult{Kind: "MutatingWebhookConfiguration", Name: name, Err: err})
+	}
+
+	for _, object := range result {
+		k.recordPatchEvent(ctx, object, options.CABundle)
+	}
+
+	return result, result.Err()
+}
+
+// CABundleInSync reports whether all objects selected by options already carry the CA bundle
+// and, if set, the failure policy. It does not modify any object.
+func (k *K8s) CABundleInSync(ctx context.Context, options PatchOptions) (bool, error) {
+	statuses, err := k.InspectCABundles(ctx, options)
+	if err != nil {
+		return false, err
+	}
+
+	for _, status := range statuses {
+		if status.Err != nil {
+			return false, status.Err
+		}
+
+		if !status.InSync {
+			slog.DebugContext(ctx, "caBundle is out of sync",
+				slog.String("kind", status.Kind),
+				slog.String("name", status.Name),
+				slog.String("webhook", status.Webhook),
+			)
+
+			return false, nil
+		}
+	}
+
+	return true, nil
 }
 
 // validatePatchOptions validates the patch options before applying them.
@@ -126,64 +323,140 @@ func validatePatchOptions(options PatchOptions) error {
 		return fmt.Errorf("invalid patch method '%s', must be 'patch' or 'update'", options.PatchMethod)
 	}
 
-	hasMutating := options.MutatingWebhookConfigurationName != ""
-	hasValidating := options.ValidatingWebhookConfigurationName != ""
-	hasFailurePolicy := options.FailurePolicyType != ""
+	hasWebhooks := len(options.MutatingWebhookConfigurationNames) > 0 || len(options.ValidatingWebhookConfigurationNames) > 0
 
 	// Failure policy is only valid when patching webhooks
-	if hasFailurePolicy && !hasMutating && !hasValidating {
+	if options.FailurePolicyType != "" && !hasWebhooks {
 		return errors.New("failurePolicy specified, but no webhook will be patched")
 	}
 
-	// If both webhooks are specified, they must have the same name
-	if hasMutating && hasValidating &&
-		options.MutatingWebhookConfigurationName != options.ValidatingWebhookConfigurationName {
-		return errors.New("mutating and validating webhook names must be the same")
-	}
-
-	return nil
-}
-
-// getWebhookName returns the webhook configuration name to use for patching.
-func getWebhookName(options PatchOptions) string {
-	if options.ValidatingWebhookConfigurationName != "" {
-		return options.ValidatingWebhookConfigurationName
-	}
-
-	return options.MutatingWebhookConfigurationName
+	return options.WebhookFilter.Validate()
 }
 
 // SaveCertsToSecret saves the provided CA, certificate and key into a secret in the specified namespace.
-//
-//nolint:revive
-func (k *K8s) SaveCertsToSecret(ctx context.Context, secretName, secretType, namespace, caName, certName, keyName string, ca, cert, key []byte) error {
+// The ca key is only saved if keys.CAKey is set and a ca key is given. The secret is created or updated with a
+// server-side apply, so other keys, labels, annotations and owner references of an existing secret are preserved.
+// The labels, annotations and owner of metadata are added. Nothing is written if the secret already holds the
+// certificates and metadata.
+func (k *K8s) SaveCertsToSecret(
+	ctx context.Context,
+	secretName, secretType, namespace string,
+	keys SecretKeys,
+	metadata SecretMetadata,
+	certs *Certificates,
+) error {
 	slog.DebugContext(ctx, "saving certificates to secret",
 		slog.String("secret", secretName),
 		slog.String("namespace", namespace),
 	)
 
-	secret := &v1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: secretName,
-		},
-		Type: v1.SecretType(secretType),
-		Data: map[string][]byte{
-			caName:   ca,
-			certName: cert,
-			keyName:  key,
-		},
+	data := secretData(keys, certs)
+	client := k.clientSet.CoreV1().Secrets(namespace)
+
+	if certs.Rollover != nil {
+		annotations := RolloverAnnotations(certs.Rollover)
+		maps.Copy(annotations, metadata.Annotations)
+		metadata.Annotations = annotations
 	}
 
-	_, err := k.clientSet.CoreV1().Secrets(namespace).Create(ctx, secret, metav1.CreateOptions{})
+	var owner *metav1.OwnerReference
+
+	if metadata.Owner != nil {
+		ref, err := k.ownerReference(ctx, *metadata.Owner, namespace)
+		if err != nil {
+			return err
+		}
+
+		owner = &ref
+	}
+
+	existing, err := client.Get(ctx, secretName, metav1.GetOptions{})
+
+	switch {
+	case k8serrors.IsNotFound(err):
+	case err != nil:
+		return fmt.Errorf("error getting secret: %w", err)
+	// The type of a secret is immutable.
+	case secretTypeOrDefault(existing.Type) != secretTypeOrDefault(v1.SecretType(secretType)):
+		return fmt.Errorf("secret '%s' has type %s, but certificates are stored in secrets of type %s. "+
+			"Delete the secret or choose a matching secret profile, e.g. opaque-legacy for secrets of older releases",
+			secretName, existing.Type, secretTypeOrDefault(v1.SecretType(secretType)))
+	case secretContains(existing, data) && hasMetadata(existing, metadata, owner):
+		slog.DebugContext(ctx, "secret already holds the certificates")
+
+		return nil
+	}
+
+	issued := k8serrors.IsNotFound(err) || !secretContains(existing, data)
+
+	applyConfig := corev1apply.Secret(secretName, namespace).
+		WithType(v1.SecretType(secretType)).
+		WithLabels(metadata.Labels).
+		WithAnnotations(metadata.Annotations).
+		WithData(data)
+
+	if owner != nil {
+		applyConfig.WithOwnerReferences(meta.OwnerReference().
+			WithAPIVersion(owner.APIVersion).
+			WithKind(owner.Kind).
+			WithName(owner.Name).
+			WithUID(owner.UID))
+	}
+
+	applied, err := client.Apply(ctx, applyConfig, metav1.ApplyOptions{
+		FieldManager: "kube-webhook-certgen",
+		Force:        true,
+		DryRun:       k.dryRun,
+	})
 	if err != nil {
-		return fmt.Errorf("error creating secret: %w", err)
+		return fmt.Errorf("error applying secret: %w", err)
 	}
 
 	slog.DebugContext(ctx, "successfully saved secret")
 
+	if issued && k.recordsEvents() {
+		k.recorder.Eventf(secretReference(applied), v1.EventTypeNormal, ReasonCertificateIssued,
+			"Issued certificate %s signed by ca %s", fingerprint(certs.Cert), fingerprint(certs.CA))
+	}
+
 	return nil
 }
 
+// secretData returns the data keys of a secret holding certs.
+func secretData(keys SecretKeys, certs *Certificates) map[string][]byte {
+	data := map[string][]byte{
+		keys.CA:   certs.CA,
+		keys.Cert: certs.Cert,
+		keys.Key:  certs.Key,
+	}
+
+	if keys.CAKey != "" && certs.CAKey != nil {
+		data[keys.CAKey] = certs.CAKey
+	}
+
+	return data
+}
+
+// secretContains reports whether every key of data is set to the same value in secret.
+func secretContains(secret *v1.Secret, data map[string][]byte) bool {
+	for key, value := range data {
+		if !bytes.Equal(secret.Data[key], value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// secretTypeOrDefault returns the type the API server assigns to a secret created with secretType.
+func secretTypeOrDefault(secretType v1.SecretType) v1.SecretType {
+	if secretType == "" {
+		return v1.SecretTypeOpaque
+	}
+
+	return secretType
+}
+
 func (k *K8s) patchAPIService(ctx context.Context, objectName string, ca []byte) error {
 	slog.InfoContext(ctx, "patching APIService",
 		slog.String("api_service", objectName),
@@ -199,7 +472,7 @@ func (k *K8s) patchAPIService(ctx context.Context, objectName string, ca []byte)
 	apiService.Spec.CABundle = ca
 	apiService.Spec.InsecureSkipTLSVerify = false
 
-	if _, err := client.Update(ctx, apiService, metav1.UpdateOptions{}); err != nil {
+	if _, err := client.Update(ctx, apiService, metav1.UpdateOptions{DryRun: k.dryRun}); err != nil {
 		return fmt.Errorf("error patching APIService: %w", err)
 	}
 
@@ -208,79 +481,59 @@ func (k *K8s) patchAPIService(ctx context.Context, objectName string, ca []byte)
 	return nil
 }
 
-// patchWebhookConfigurations patches webhook configurations with CA bundle and optional failure policy.
-func (k *K8s) patchWebhookConfigurations(
+// patchValidatingWebhook patches a validating webhook with the specified method (patch or update).
+func (k *K8s) patchValidatingWebhook(
 	ctx context.Context,
 	configurationName string,
 	ca []byte,
 	failurePolicy admissionregistrationv1.FailurePolicyType,
-	patchMutating,
-	patchValidating bool,
+	filter WebhookFilter,
 	patchMethod string,
 ) error {
-	slog.InfoContext(ctx, "patching webhook configurations",
+	slog.InfoContext(ctx, "patching validating webhook configuration",
 		slog.String("configuration_name", configurationName),
-		slog.Bool("patch_mutating", patchMutating),
-		slog.Bool("patch_validating", patchValidating),
 		slog.String("failure_policy", string(failurePolicy)),
 	)
 
-	if patchValidating {
-		if err := k.patchValidatingWebhook(ctx, configurationName, ca, failurePolicy, patchMethod); err != nil {
-			return err
-		}
-	} else {
-		slog.DebugContext(ctx, "validating hook patching not required")
-	}
-
-	if patchMutating {
-		if err := k.patchMutatingWebhook(ctx, configurationName, ca, failurePolicy, patchMethod); err != nil {
+	if patchMethod == "update" {
+		if err := k.updateValidatingWebhook(ctx, configurationName, ca, failurePolicy, filter); err != nil {
 			return err
 		}
-	} else {
-		slog.DebugContext(ctx, "mutating hook patching not required")
 	}
 
-	slog.InfoContext(ctx, "successfully patched webhook configuration(s)")
-
-	return nil
+	return k.applyValidatingWebhook(ctx, configurationName, ca, failurePolicy, filter)
 }
 
-// patchValidatingWebhook patches a validating webhook with the specified method (patch or update).
-func (k *K8s) patchValidatingWebhook(
+// patchMutatingWebhook patches a mutating webhook with the specified method (patch or update).
+func (k *K8s) patchMutatingWebhook(
 	ctx context.Context,
 	configurationName string,
 	ca []byte,
 	failurePolicy admissionregistrationv1.FailurePolicyType,
+	filter WebhookFilter,
 	patchMethod string,
 ) error {
+	slog.InfoContext(ctx, "patching mutating webhook configuration",
+		slog.String("configuration_name", configurationName),
+		slog.String("failure_policy", string(failurePolicy)),
+	)
+
 	if patchMethod == "update" {
-		if err := k.updateValidatingWebhook(ctx, configurationName, ca, failurePolicy); err != nil {
+		if err := k.updateMutatingWebhook(ctx, configurationName, ca, failurePolicy, filter); err != nil {
 			return err
 		}
 	}
 
-	return k.applyValidatingWebhook(ctx, configurationName, ca, failurePolicy)
+	return k.applyMutatingWebhook(ctx, configurationName, ca, failurePolicy, filter)
 }
 
-// patchMutatingWebhook patches a mutating webhook with the specified method (patch or update).
-func (k *K8s) patchMutatingWebhook(
+func (k *K8s) applyValidatingWebhook(
 	ctx context.Context,
 	configurationName string,
 	ca []byte,
 	failurePolicy admissionregistrationv1.FailurePolicyType,
-	patchMethod string,
+	filter WebhookFilter,
 ) error {
-	if patchMethod == "update" {
-		if err := k.updateMutatingWebhook(ctx, configurationName, ca, failurePolicy); err != nil {
-			return err
-		}
-	}
-
-	return k.applyMutatingWebhook(ctx, configurationName, ca, failurePolicy)
-}
-
-func (k *K8s) applyValidatingWebhook(ctx context.Context, configurationName string, ca []byte, failurePolicy admissionregistrationv1.FailurePolicyType) error {
 	valHook, err := k.clientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, configurationName, metav1.GetOptions{})
 	if err != nil {
 		return fmt.Errorf("failed getting validating webhook: %w", err)
@@ -297,14 +550,36 @@ func (k *K8s) applyValidatingWebhook(ctx context.Context, configurationName stri
 		Webhooks: make([]admissionapplyv1.ValidatingWebhookApplyConfiguration, 0, len(valHook.Webhooks)),
 	}
 
+	owned, err := admissionapplyv1.ExtractValidatingWebhookConfiguration(valHook, "kube-webhook-certgen")
+	if err != nil {
+		return fmt.Errorf("failed extracting owned fields of validating webhook: %w", err)
+	}
+
+	matched := false
+
 	for i := range valHook.Webhooks {
 		config := admissionapplyv1.ValidatingWebhookApplyConfiguration{
 			Name: &valHook.Webhooks[i].Name,
-			ClientConfig: &admissionapplyv1.WebhookClientConfigApplyConfiguration{
-				CABundle: ca,
-			},
 		}
 
+		if !filter.Matches(valHook.Webhooks[i].Name, valHook.Webhooks[i].ClientConfig) {
+			// Excluded entries keep the caBundle and failurePolicy an earlier unfiltered apply took ownership of,
+			// as omitting them would remove them. Fields of other managers are left alone.
+			for _, ownedConfig := range owned.Webhooks {
+				if *ownedConfig.Name == valHook.Webhooks[i].Name {
+					config.ClientConfig = ownedConfig.ClientConfig
+					config.FailurePolicy = ownedConfig.FailurePolicy
+				}
+			}
+
+			applyConfig.Webhooks = append(applyConfig.Webhooks, config)
+
+			continue
+		}
+
+		matched = true
+		config.ClientConfig = &admissionapplyv1.WebhookClientConfigApplyConfiguration{CABundle: ca}
+
 		if failurePolicy != "" {
 			config.FailurePolicy = &failurePolicy
 		}
@@ -312,9 +587,14 @@ func (k *K8s) applyValidatingWebhook(ctx context.Context, configurationName stri
 		applyConfig.Webhooks = append(applyConfig.Webhooks, config)
 	}
 
+	if !matched {
+		return ErrNoWebhookMatched
+	}
+
 	if _, err = k.clientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Apply(ctx, applyConfig, metav1.ApplyOptions{
 		FieldManager: "kube-webhook-certgen",
 		Force:        true,
+		DryRun:       k.dryRun,
 	}); err != nil {
 		return fmt.Errorf("failed patching validating webhook: %w", err)
 	}
@@ -324,7 +604,13 @@ func (k *K8s) applyValidatingWebhook(ctx context.Context, configurationName stri
 	return nil
 }
 
-func (k *K8s) updateValidatingWebhook(ctx context.Context, configurationName string, ca []byte, failurePolicy admissionregistrationv1.FailurePolicyType) error {
+func (k *K8s) updateValidatingWebhook(
+	ctx context.Context,
+	configurationName string,
+	ca []byte,
+	failurePolicy admissionregistrationv1.FailurePolicyType,
+	filter WebhookFilter,
+) error {
 	valHook, err := k.clientSet.
 		AdmissionregistrationV1().
 		ValidatingWebhookConfigurations().
@@ -333,8 +619,15 @@ func (k *K8s) updateValidatingWebhook(ctx context.Context, configurationName str
 		return fmt.Errorf("failed getting validating webhook: %w", err)
 	}
 
+	matched := false
+
 	for i := range valHook.Webhooks {
 		h := &valHook.Webhooks[i]
+		if !filter.Matches(h.Name, h.ClientConfig) {
+			continue
+		}
+
+		matched = true
 
 		h.ClientConfig.CABundle = ca
 		if failurePolicy != "" {
@@ -342,9 +635,13 @@ func (k *K8s) updateValidatingWebhook(ctx context.Context, configurationName str
 		}
 	}
 
+	if !matched {
+		return ErrNoWebhookMatched
+	}
+
 	if _, err = k.clientSet.AdmissionregistrationV1().
 		ValidatingWebhookConfigurations().
-		Update(ctx, valHook, metav1.UpdateOptions{}); err != nil {
+		Update(ctx, valHook, metav1.UpdateOptions{DryRun: k.dryRun}); err != nil {
 		return fmt.Errorf("failed patching validating webhook: %w", err)
 	}
 
@@ -353,7 +650,13 @@ func (k *K8s) updateValidatingWebhook(ctx context.Context, configurationName str
 	return nil
 }
 
-func (k *K8s) applyMutatingWebhook(ctx context.Context, configurationName string, ca []byte, failurePolicy admissionregistrationv1.FailurePolicyType) error {
+func (k *K8s) applyMutatingWebhook(
+	ctx context.Context,
+	configurationName string,
+	ca []byte,
+	failurePolicy admissionregistrationv1.FailurePolicyType,
+	filter WebhookFilter,
+) error {
 	mutHook, err := k.clientSet.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, configurationName, metav1.GetOptions{})
 	if err != nil {
 		return fmt.Errorf("failed getting mutating webhook: %w", err)
@@ -370,14 +673,36 @@ func (k *K8s) applyMutatingWebhook(ctx context.Context, configurationName string
 		Webhooks: make([]admissionapplyv1.MutatingWebhookApplyConfiguration, 0, len(mutHook.Webhooks)),
 	}
 
+	owned, err := admissionapplyv1.ExtractMutatingWebhookConfiguration(mutHook, "kube-webhook-certgen")
+	if err != nil {
+		return fmt.Errorf("failed extracting owned fields of mutating webhook: %w", err)
+	}
+
+	matched := false
+
 	for i := range mutHook.Webhooks {
 		config := admissionapplyv1.MutatingWebhookApplyConfiguration{
 			Name: &mutHook.Webhooks[i].Name,
-			ClientConfig: &admissionapplyv1.WebhookClientConfigApplyConfiguration{
-				CABundle: ca,
-			},
 		}
 
+		if !filter.Matches(mutHook.Webhooks[i].Name, mutHook.Webhooks[i].ClientConfig) {
+			// Excluded entries keep the caBundle and failurePolicy an earlier unfiltered apply took ownership of,
+			// as omitting them would remove them. Fields of other managers are left alone.
+			for _, ownedConfig := range owned.Webhooks {
+				if *ownedConfig.Name == mutHook.Webhooks[i].Name {
+					config.ClientConfig = ownedConfig.ClientConfig
+					config.FailurePolicy = ownedConfig.FailurePolicy
+				}
+			}
+
+			applyConfig.Webhooks = append(applyConfig.Webhooks, config)
+
+			continue
+		}
+
+		matched = true
+		config.ClientConfig = &admissionapplyv1.WebhookClientConfigApplyConfiguration{CABundle: ca}
+
 		if failurePolicy != "" {
 			config.FailurePolicy = &failurePolicy
 		}
@@ -385,9 +710,14 @@ func (k *K8s) applyMutatingWebhook(ctx context.Context, configurationName string
 		applyConfig.Webhooks = append(applyConfig.Webhooks, config)
 	}
 
+	if !matched {
+		return ErrNoWebhookMatched
+	}
+
 	if _, err = k.clientSet.AdmissionregistrationV1().MutatingWebhookConfigurations().Apply(ctx, applyConfig, metav1.ApplyOptions{
 		FieldManager: "kube-webhook-certgen",
 		Force:        true,
+		DryRun:       k.dryRun,
 	}); err != nil {
 		return fmt.Errorf("failed patching mutating webhook: %w", err)
 	}
@@ -397,7 +727,13 @@ func (k *K8s) applyMutatingWebhook(ctx context.Context, configurationName string
 	return nil
 }
 
-func (k *K8s) updateMutatingWebhook(ctx context.Context, configurationName string, ca []byte, failurePolicy admissionregistrationv1.FailurePolicyType) error {
+func (k *K8s) updateMutatingWebhook(
+	ctx context.Context,
+	configurationName string,
+	ca []byte,
+	failurePolicy admissionregistrationv1.FailurePolicyType,
+	filter WebhookFilter,
+) error {
 	mutHook, err := k.clientSet.
 		AdmissionregistrationV1().
 		MutatingWebhookConfigurations().
@@ -406,8 +742,15 @@ func (k *K8s) updateMutatingWebhook(ctx context.Context, configurationName strin
 		return fmt.Errorf("failed getting mutating webhook: %w", err)
 	}
 
+	matched := false
+
 	for i := range mutHook.Webhooks {
 		h := &mutHook.Webhooks[i]
+		if !filter.Matches(h.Name, h.ClientConfig) {
+			continue
+		}
+
+		matched = true
 
 		h.ClientConfig.CABundle = ca
 		if failurePolicy != "" {
@@ -415,9 +758,13 @@ func (k *K8s) updateMutatingWebhook(ctx context.Context, configurationName strin
 		}
 	}
 
+	if !matched {
+		return ErrNoWebhookMatched
+	}
+
 	if _, err = k.clientSet.AdmissionregistrationV1().
 		MutatingWebhookConfigurations().
-		Update(ctx, mutHook, metav1.UpdateOptions{}); err != nil {
+		Update(ctx, mutHook, metav1.UpdateOptions{DryRun: k.dryRun}); err != nil {
 		return fmt.Errorf("failed patching mutating webhook: %w", err)
 	}
 
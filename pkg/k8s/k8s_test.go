@@ -11,8 +11,10 @@ import (
 	"github.com/stretchr/testify/require"
 	admissionv1 "k8s.io/api/admissionregistration/v1"
 	v1 "k8s.io/api/core/v1"
+	apiextensionsfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
 	"k8s.io/client-go/kubernetes/fake"
 	apiregistrationv1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
 	aggregatorfake "k8s.io/kube-aggregator/pkg/client/clientset_generated/clientset/fake"
@@ -26,7 +28,10 @@ const (
 	testNamespace      = "7cad5f92-c0d5-4bc9-87a3-6f44d5a5619d"
 )
 
-var fail = admissionv1.Fail
+var (
+	fail           = admissionv1.Fail
+	testSecretKeys = SecretKeys{CA: "ca.crt", Cert: "tls.crt", Key: "tls.key"}
+)
 
 func genSecretData() ([]byte, []byte, []byte) {
 	ca := make([]byte, 4)
@@ -41,11 +46,17 @@ func genSecretData() ([]byte, []byte, []byte) {
 
 func newTestSimpleK8s(objects ...runtime.Object) *K8s {
 	return &K8s{
-		clientSet:           fake.NewSimpleClientset(objects...),
-		aggregatorClientSet: aggregatorfake.NewSimpleClientset(),
+		clientSet:              fake.NewClientset(objects...),
+		aggregatorClientSet:    aggregatorfake.NewSimpleClientset(),
+		apiExtensionsClientSet: apiextensionsfake.NewSimpleClientset(),
+		dynamicClient:          newTestDynamicClient(),
 	}
 }
 
+func newTestDynamicClient(objects ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	return dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), objects...)
+}
+
 func TestGetCaFromCertificate(t *testing.T) {
 	t.Parallel()
 
@@ -79,7 +90,7 @@ func TestSaveCertsToSecret(t *testing.T) {
 
 	ctx := contextWithDeadline(t)
 
-	err := k.SaveCertsToSecret(ctx, testSecretName, testSecretType, testNamespace, "ca.crt", "tls.crt", "tls.key", ca, cert, key)
+	err := k.SaveCertsToSecret(ctx, testSecretName, testSecretType, testNamespace, testSecretKeys, SecretMetadata{}, &Certificates{CA: ca, Cert: cert, Key: key})
 	require.NoError(t, err)
 
 	secret, _ := k.clientSet.CoreV1().Secrets(testNamespace).Get(ctx, testSecretName, metav1.GetOptions{})
@@ -96,7 +107,7 @@ func TestSaveThenLoadSecret(t *testing.T) {
 	ca, cert, key := genSecretData()
 	ctx := contextWithDeadline(t)
 
-	err := k.SaveCertsToSecret(ctx, testSecretName, testSecretType, testNamespace, "ca.crt", "tls.crt", "tls.key", ca, cert, key)
+	err := k.SaveCertsToSecret(ctx, testSecretName, testSecretType, testNamespace, testSecretKeys, SecretMetadata{}, &Certificates{CA: ca, Cert: cert, Key: key})
 	require.NoError(t, err)
 
 	retrievedCert, err := k.GetCaFromSecret(ctx, "ca.crt", testSecretName, testNamespace)
@@ -105,6 +116,141 @@ func TestSaveThenLoadSecret(t *testing.T) {
 	require.Equal(t, string(ca), string(retrievedCert))
 }
 
+func TestSaveCertsToExistingSecret(t *testing.T) {
+	t.Parallel()
+
+	ca, cert, key := genSecretData()
+
+	// A secret pre-created empty, e.g. by a chart.
+	k := newTestSimpleK8s(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        testSecretName,
+			Namespace:   testNamespace,
+			Labels:      map[string]string{"app": "webhook"},
+			Annotations: map[string]string{"note": "pre-created"},
+		},
+		Type: testSecretType,
+		Data: map[string][]byte{"tls.crt": {}, "tls.key": {}, "extra": []byte("extra")},
+	})
+
+	ctx := contextWithDeadline(t)
+
+	_, err := k.GetCertsFromSecret(ctx, testSecretName, testNamespace, testSecretKeys)
+	require.ErrorIs(t, err, ErrNoCertificates)
+
+	certs := &Certificates{CA: ca, Cert: cert, Key: key}
+	require.NoError(t, k.SaveCertsToSecret(ctx, testSecretName, testSecretType, testNamespace, testSecretKeys, SecretMetadata{}, certs))
+
+	secret, err := k.clientSet.CoreV1().Secrets(testNamespace).Get(ctx, testSecretName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, map[string][]byte{"ca.crt": ca, "tls.crt": cert, "tls.key": key, "extra": []byte("extra")}, secret.Data)
+	require.Equal(t, map[string]string{"app": "webhook"}, secret.Labels)
+	require.Equal(t, map[string]string{"note": "pre-created"}, secret.Annotations)
+
+	retrieved, err := k.GetCertsFromSecret(ctx, testSecretName, testNamespace, testSecretKeys)
+	require.NoError(t, err)
+	require.Equal(t, certs, retrieved)
+
+	// Saving the same certificates again must not write the secret.
+	clientSet := k.clientSet.(*fake.Clientset)
+	clientSet.ClearActions()
+
+	require.NoError(t, k.SaveCertsToSecret(ctx, testSecretName, testSecretType, testNamespace, testSecretKeys, SecretMetadata{}, certs))
+
+	for _, action := range clientSet.Actions() {
+		require.Equal(t, "get", action.GetVerb())
+	}
+}
+
+func TestSaveCertsToSecretTypeMismatch(t *testing.T) {
+	t.Parallel()
+
+	ca, cert, key := genSecretData()
+
+	k := newTestSimpleK8s(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testSecretName,
+			Namespace: testNamespace,
+		},
+		Type: v1.SecretTypeOpaque,
+		Data: map[string][]byte{"ca": ca, "cert": cert, "key": key},
+	})
+
+	ctx := contextWithDeadline(t)
+
+	err := k.SaveCertsToSecret(ctx, testSecretName, testSecretType, testNamespace, testSecretKeys, SecretMetadata{}, &Certificates{CA: ca, Cert: cert, Key: key})
+	require.ErrorContains(t, err, "has type Opaque")
+
+	secret, err := k.clientSet.CoreV1().Secrets(testNamespace).Get(ctx, testSecretName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, map[string][]byte{"ca": ca, "cert": cert, "key": key}, secret.Data)
+}
+
+func TestGetCertsFromSecret(t *testing.T) {
+	t.Parallel()
+
+	ca, cert, key := genSecretData()
+
+	k := newTestSimpleK8s(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testSecretName,
+			Namespace: testNamespace,
+		},
+		Data: map[string][]byte{"ca": ca, "cert": cert},
+	})
+
+	ctx := contextWithDeadline(t)
+	keys := SecretKeys{CA: "ca", Cert: "cert", Key: "key", CAKey: "ca.key"}
+
+	_, err := k.GetCertsFromSecret(ctx, "missing", testNamespace, keys)
+	require.ErrorIs(t, err, ErrNoSecret)
+
+	_, err = k.GetCertsFromSecret(ctx, testSecretName, testNamespace, keys)
+	require.ErrorContains(t, err, "'key'")
+
+	err = k.SaveCertsToSecret(ctx, testSecretName, "Opaque", testNamespace, keys, SecretMetadata{}, &Certificates{CA: ca, Cert: cert, Key: key, CAKey: key})
+	require.NoError(t, err)
+
+	keys.CA = "ca.crt"
+
+	retrieved, err := k.GetCertsFromSecret(ctx, testSecretName, testNamespace, keys)
+	require.NoError(t, err)
+	require.Equal(t, &Certificates{CA: ca, Cert: cert, Key: key, CAKey: key}, retrieved)
+
+	keys.CAKey = ""
+
+	retrieved, err = k.GetCertsFromSecret(ctx, testSecretName, testNamespace, keys)
+	require.NoError(t, err)
+	require.Nil(t, retrieved.CAKey)
+}
+
+func TestGetKeyPairFromSecret(t *testing.T) {
+	t.Parallel()
+
+	_, cert, key := genSecretData()
+
+	k := newTestSimpleK8s(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testSecretName,
+			Namespace: testNamespace,
+		},
+		Data: map[string][]byte{"tls.crt": cert, "tls.key": key},
+	})
+
+	ctx := contextWithDeadline(t)
+
+	retrievedCert, retrievedKey, err := k.GetKeyPairFromSecret(ctx, testSecretName, testNamespace, "tls.crt", "tls.key")
+	require.NoError(t, err)
+	require.Equal(t, cert, retrievedCert)
+	require.Equal(t, key, retrievedKey)
+
+	_, _, err = k.GetKeyPairFromSecret(ctx, testSecretName, testNamespace, "tls.crt", "ca.key")
+	require.ErrorContains(t, err, "'ca.key'")
+
+	_, _, err = k.GetKeyPairFromSecret(ctx, "missing", testNamespace, "tls.crt", "tls.key")
+	require.ErrorIs(t, err, ErrNoSecret)
+}
+
 func TestPatchWebhookConfigurations(t *testing.T) {
 	t.Parallel()
 
@@ -129,7 +275,13 @@ func TestPatchWebhookConfigurations(t *testing.T) {
 
 	ctx := contextWithDeadline(t)
 
-	if err := k.patchWebhookConfigurations(ctx, testWebhookName, ca, fail, true, true, "update"); err != nil {
+	if _, err := k.PatchObjects(ctx, PatchOptions{
+		ValidatingWebhookConfigurationNames: []string{testWebhookName},
+		MutatingWebhookConfigurationNames:   []string{testWebhookName},
+		CABundle:                            ca,
+		FailurePolicyType:                   fail,
+		PatchMethod:                         "update",
+	}); err != nil {
 		t.Fatalf("Unexpected error patching webhooks: %s: %v", err.Error(), errors.Unwrap(err))
 	}
 
@@ -182,6 +334,50 @@ func TestPatchWebhookConfigurations(t *testing.T) {
 	}
 }
 
+func TestCABundleInSync(t *testing.T) {
+	t.Parallel()
+
+	ctx := contextWithDeadline(t)
+
+	k := testK8sWithUnpatchedObjects()
+
+	o := PatchOptions{
+		ValidatingWebhookConfigurationNames: []string{testWebhookName},
+		MutatingWebhookConfigurationNames:   []string{testWebhookName},
+		APIServiceNames:                     []string{testAPIServiceName},
+		CABundle:                            []byte("foo"),
+		FailurePolicyType:                   fail,
+		PatchMethod:                         "update",
+	}
+
+	inSync, err := k.CABundleInSync(ctx, o)
+	require.NoError(t, err)
+	require.False(t, inSync)
+
+	_, err = k.PatchObjects(ctx, o)
+	require.NoError(t, err)
+
+	inSync, err = k.CABundleInSync(ctx, o)
+	require.NoError(t, err)
+	require.True(t, inSync)
+
+	o.FailurePolicyType = admissionv1.Ignore
+
+	inSync, err = k.CABundleInSync(ctx, o)
+	require.NoError(t, err)
+	require.False(t, inSync)
+
+	o.FailurePolicyType = ""
+	o.CABundle = []byte("bar")
+
+	inSync, err = k.CABundleInSync(ctx, o)
+	require.NoError(t, err)
+	require.False(t, inSync)
+
+	_, err = newTestSimpleK8s().CABundleInSync(ctx, o)
+	require.Error(t, err)
+}
+
 func Test_Patching_objects(t *testing.T) {
 	t.Parallel()
 
@@ -199,23 +395,7 @@ func Test_Patching_objects(t *testing.T) {
 				FailurePolicyType: admissionv1.Fail,
 			}
 
-			if err := k.PatchObjects(ctx, o); err == nil {
-				t.Fatalf("Expected error while patching")
-			}
-		})
-
-		// This is to preserve old behavior and log format, it could be improved.
-		t.Run("different_non_empty_names_are_specified_for_validating_and_mutating_webhook", func(t *testing.T) {
-			t.Parallel()
-
-			k := testK8sWithUnpatchedObjects()
-
-			o := PatchOptions{
-				ValidatingWebhookConfigurationName: "foo",
-				MutatingWebhookConfigurationName:   "bar",
-			}
-
-			if err := k.PatchObjects(ctx, o); err == nil {
+			if _, err := k.PatchObjects(ctx, o); err == nil {
 				t.Fatalf("Expected error while patching")
 			}
 		})
@@ -226,10 +406,10 @@ func Test_Patching_objects(t *testing.T) {
 			k := newTestSimpleK8s()
 
 			o := PatchOptions{
-				ValidatingWebhookConfigurationName: "foo",
+				ValidatingWebhookConfigurationNames: []string{"foo"},
 			}
 
-			if err := k.PatchObjects(ctx, o); err == nil {
+			if _, err := k.PatchObjects(ctx, o); err == nil {
 				t.Fatalf("Expected error while patching")
 			}
 		})
@@ -240,10 +420,10 @@ func Test_Patching_objects(t *testing.T) {
 			k := newTestSimpleK8s()
 
 			o := PatchOptions{
-				APIServiceName: "foo",
+				APIServiceNames: []string{"foo"},
 			}
 
-			if err := k.PatchObjects(ctx, o); err == nil {
+			if _, err := k.PatchObjects(ctx, o); err == nil {
 				t.Fatalf("Expected error while patching")
 			}
 		})
@@ -255,12 +435,12 @@ func Test_Patching_objects(t *testing.T) {
 		k := testK8sWithUnpatchedObjects()
 
 		o := PatchOptions{
-			APIServiceName: testAPIServiceName,
-			CABundle:       []byte("foo"),
-			PatchMethod:    "update",
+			APIServiceNames: []string{testAPIServiceName},
+			CABundle:        []byte("foo"),
+			PatchMethod:     "update",
 		}
 
-		if err := k.PatchObjects(ctx, o); err != nil {
+		if _, err := k.PatchObjects(ctx, o); err != nil {
 			t.Fatalf("Unexpected error while patching objects: %v", err)
 		}
 
@@ -299,11 +479,11 @@ func Test_Patching_objects(t *testing.T) {
 		k := testK8sWithUnpatchedObjects()
 
 		o := PatchOptions{
-			ValidatingWebhookConfigurationName: testWebhookName,
-			PatchMethod:                        "update",
+			ValidatingWebhookConfigurationNames: []string{testWebhookName},
+			PatchMethod:                         "update",
 		}
 
-		if err := k.PatchObjects(ctx, o); err != nil {
+		if _, err := k.PatchObjects(ctx, o); err != nil {
 			t.Fatalf("Unexpected error patching objects: %v", err)
 		}
 	})
@@ -314,14 +494,105 @@ func Test_Patching_objects(t *testing.T) {
 		k := testK8sWithUnpatchedObjects()
 
 		o := PatchOptions{
-			MutatingWebhookConfigurationName: testWebhookName,
-			PatchMethod:                      "update",
+			MutatingWebhookConfigurationNames: []string{testWebhookName},
+			PatchMethod:                       "update",
 		}
 
-		if err := k.PatchObjects(ctx, o); err != nil {
+		if _, err := k.PatchObjects(ctx, o); err != nil {
 			t.Fatalf("Unexpected error patching objects: %v", err)
 		}
 	})
+
+	t.Run("allows_patching_distinct_validating_and_mutating_webhooks", func(t *testing.T) {
+		t.Parallel()
+
+		k := newTestSimpleK8s(
+			&admissionv1.ValidatingWebhookConfiguration{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+				Webhooks:   []admissionv1.ValidatingWebhook{{Name: "foo"}},
+			},
+			&admissionv1.MutatingWebhookConfiguration{
+				ObjectMeta: metav1.ObjectMeta{Name: "bar"},
+				Webhooks:   []admissionv1.MutatingWebhook{{Name: "bar"}},
+			},
+		)
+
+		o := PatchOptions{
+			ValidatingWebhookConfigurationNames: []string{"foo"},
+			MutatingWebhookConfigurationNames:   []string{"bar"},
+			PatchMethod:                         "update",
+		}
+
+		if _, err := k.PatchObjects(ctx, o); err != nil {
+			t.Fatalf("Unexpected error patching objects: %v", err)
+		}
+	})
+
+	t.Run("fails_if_no_webhook_matched", func(t *testing.T) {
+		t.Parallel()
+
+		k := newTestSimpleK8s(
+			&admissionv1.ValidatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+			&admissionv1.MutatingWebhookConfiguration{
+				ObjectMeta: metav1.ObjectMeta{Name: "bar"},
+				Webhooks:   []admissionv1.MutatingWebhook{{Name: "bar"}},
+			},
+		)
+
+		for _, patchMethod := range []string{"patch", "update"} {
+			o := PatchOptions{
+				ValidatingWebhookConfigurationNames: []string{"foo"},
+				MutatingWebhookConfigurationNames:   []string{"bar"},
+				PatchMethod:                         patchMethod,
+				WebhookFilter:                       WebhookFilter{Names: []string{"baz"}},
+			}
+
+			result, err := k.PatchObjects(ctx, o)
+			require.ErrorIs(t, err, ErrNoWebhookMatched)
+			require.Len(t, result, 2)
+			require.ErrorIs(t, result[0].Err, ErrNoWebhookMatched)
+			require.ErrorIs(t, result[1].Err, ErrNoWebhookMatched)
+
+			_, err = k.CABundleInSync(ctx, o)
+			require.ErrorIs(t, err, ErrNoWebhookMatched)
+		}
+	})
+
+	t.Run("reports_result_for_each_object", func(t *testing.T) {
+		t.Parallel()
+
+		k := testK8sWithUnpatchedObjects()
+
+		o := PatchOptions{
+			ValidatingWebhookConfigurationNames: []string{"missing", testWebhookName},
+			APIServiceNames:                     []string{testAPIServiceName},
+			CABundle:                            []byte("foo"),
+			PatchMethod:                         "patch",
+		}
+
+		result, err := k.PatchObjects(ctx, o)
+		require.ErrorContains(t, err, "ValidatingWebhookConfiguration 'missing'")
+		require.Len(t, result, 3)
+
+		require.Equal(t, "APIService", result[0].Kind)
+		require.Equal(t, testAPIServiceName, result[0].Name)
+		require.NoError(t, result[0].Err)
+
+		require.Equal(t, "ValidatingWebhookConfiguration", result[1].Kind)
+		require.Equal(t, "missing", result[1].Name)
+		require.Error(t, result[1].Err)
+
+		require.Equal(t, testWebhookName, result[2].Name)
+		require.NoError(t, result[2].Err)
+
+		inSync, err := k.CABundleInSync(ctx, PatchOptions{
+			ValidatingWebhookConfigurationNames: []string{testWebhookName},
+			APIServiceNames:                     []string{testAPIServiceName},
+			CABundle:                            []byte("foo"),
+		})
+		require.NoError(t, err)
+		require.True(t, inSync, "objects after the failed one must still be patched")
+	})
 }
 
 const (
@@ -377,7 +648,9 @@ func testK8sWithUnpatchedObjects() *K8s {
 	}
 
 	return &K8s{
-		clientSet:           fake.NewSimpleClientset(secret, validatingWebhook, mutatingWebhook),
-		aggregatorClientSet: aggregatorfake.NewSimpleClientset(apiService),
+		clientSet:              fake.NewSimpleClientset(secret, validatingWebhook, mutatingWebhook),
+		aggregatorClientSet:    aggregatorfake.NewSimpleClientset(apiService),
+		apiExtensionsClientSet: apiextensionsfake.NewSimpleClientset(),
+		dynamicClient:          newTestDynamicClient(),
 	}
 }
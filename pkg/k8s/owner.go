@@ -0,0 +1,102 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// SecretMetadata is added to the secret holding the certificates. Metadata set by others is kept.
+type SecretMetadata struct {
+	Labels      map[string]string
+	Annotations map[string]string
+	// Owner, if set, is added as owner reference, so the secret is garbage collected together with it.
+	Owner *ObjectReference
+}
+
+// ObjectReference names an object, e.g. apps/v1 Deployment webhook.
+type ObjectReference struct {
+	APIVersion string
+	Kind       string
+	Name       string
+}
+
+// ownerReference looks up the object referenced by ref and returns an owner reference to it.
+// Namespaced owners are looked up in namespace, as the API server only accepts owners in the namespace of the dependent
+// or cluster-scoped owners.
+func (k *K8s) ownerReference(ctx context.Context, ref ObjectReference, namespace string) (metav1.OwnerReference, error) {
+	if ref.APIVersion == "" || ref.Kind == "" || ref.Name == "" {
+		return metav1.OwnerReference{}, fmt.Errorf("owner reference requires api version, kind and name, got '%s', '%s' and '%s'",
+			ref.APIVersion, ref.Kind, ref.Name)
+	}
+
+	groupVersion, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return metav1.OwnerReference{}, fmt.Errorf("invalid owner api version '%s': %w", ref.APIVersion, err)
+	}
+
+	resources, err := k.clientSet.Discovery().ServerResourcesForGroupVersion(ref.APIVersion)
+	if err != nil {
+		return metav1.OwnerReference{}, fmt.Errorf("error discovering resources of '%s': %w", ref.APIVersion, err)
+	}
+
+	for _, resource := range resources.APIResources {
+		// Subresources share the kind of their parent.
+		if resource.Kind != ref.Kind || strings.Contains(resource.Name, "/") {
+			continue
+		}
+
+		var client dynamic.ResourceInterface = k.dynamicClient.Resource(groupVersion.WithResource(resource.Name))
+		if resource.Namespaced {
+			client = k.dynamicClient.Resource(groupVersion.WithResource(resource.Name)).Namespace(namespace)
+		}
+
+		owner, err := client.Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return metav1.OwnerReference{}, fmt.Errorf("error getting owner %s '%s': %w", ref.Kind, ref.Name, err)
+		}
+
+		return metav1.OwnerReference{
+			APIVersion: ref.APIVersion,
+			Kind:       ref.Kind,
+			Name:       ref.Name,
+			UID:        owner.GetUID(),
+		}, nil
+	}
+
+	return metav1.OwnerReference{}, fmt.Errorf("kind '%s' is not served by '%s'", ref.Kind, ref.APIVersion)
+}
+
+// hasMetadata reports whether object carries the labels and annotations of metadata and the owner reference owner.
+func hasMetadata(object metav1.Object, metadata SecretMetadata, owner *metav1.OwnerReference) bool {
+	if !containsAll(object.GetLabels(), metadata.Labels) || !containsAll(object.GetAnnotations(), metadata.Annotations) {
+		return false
+	}
+
+	if owner == nil {
+		return true
+	}
+
+	for _, ref := range object.GetOwnerReferences() {
+		if ref.UID == owner.UID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// containsAll reports whether current holds every entry of desired.
+func containsAll(current, desired map[string]string) bool {
+	for key, value := range desired {
+		if currentValue, ok := current[key]; !ok || currentValue != value {
+			return false
+		}
+	}
+
+	return true
+}
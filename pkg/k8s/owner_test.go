@@ -0,0 +1,72 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	aggregatorfake "k8s.io/kube-aggregator/pkg/client/clientset_generated/clientset/fake"
+)
+
+func TestSaveCertsToSecretWithMetadata(t *testing.T) {
+	t.Parallel()
+
+	const ownerUID = types.UID("7f0c3a5e-3c1e-4d6b-9a57-0a4f3c2b1d00")
+
+	clientSet := fake.NewClientset()
+	clientSet.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{{
+		GroupVersion: "apps/v1",
+		APIResources: []metav1.APIResource{
+			{Name: "deployments", Namespaced: true, Kind: "Deployment"},
+			{Name: "deployments/scale", Namespaced: true, Kind: "Scale"},
+		},
+	}}
+
+	k := &K8s{
+		clientSet:           clientSet,
+		aggregatorClientSet: aggregatorfake.NewSimpleClientset(),
+		dynamicClient: newTestDynamicClient(&unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]any{"name": "webhook", "namespace": testNamespace, "uid": string(ownerUID)},
+		}}),
+	}
+
+	ctx := contextWithDeadline(t)
+	ca, cert, key := genSecretData()
+	certs := &Certificates{CA: ca, Cert: cert, Key: key}
+
+	metadata := SecretMetadata{
+		Labels:      map[string]string{"app.kubernetes.io/name": "webhook"},
+		Annotations: map[string]string{"example.com/owner": "team"},
+		Owner:       &ObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "webhook"},
+	}
+
+	require.NoError(t, k.SaveCertsToSecret(ctx, testSecretName, testSecretType, testNamespace, testSecretKeys, SecretMetadata{}, certs))
+	require.NoError(t, k.SaveCertsToSecret(ctx, testSecretName, testSecretType, testNamespace, testSecretKeys, metadata, certs))
+
+	secret, err := clientSet.CoreV1().Secrets(testNamespace).Get(ctx, testSecretName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, metadata.Labels, secret.Labels)
+	require.Equal(t, metadata.Annotations, secret.Annotations)
+	require.Equal(t, []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "webhook", UID: ownerUID}}, secret.OwnerReferences)
+	require.Equal(t, cert, secret.Data["tls.crt"])
+
+	for name, owner := range map[string]ObjectReference{
+		"incomplete":      {APIVersion: "apps/v1", Kind: "Deployment"},
+		"unknown_kind":    {APIVersion: "apps/v1", Kind: "StatefulSet", Name: "webhook"},
+		"missing_object":  {APIVersion: "apps/v1", Kind: "Deployment", Name: "missing"},
+		"unknown_version": {APIVersion: "example.com/v1", Kind: "Widget", Name: "webhook"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := k.SaveCertsToSecret(ctx, testSecretName, testSecretType, testNamespace, testSecretKeys, SecretMetadata{Owner: &owner}, certs)
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// SecretProfile selects the type and the key names of the secret holding the certificates.
+type SecretProfile string
+
+const (
+	// SecretProfileTLS stores the certificates in a kubernetes.io/tls secret under ca.crt, tls.crt and tls.key
+	// and the ca key under ca.key.
+	SecretProfileTLS SecretProfile = "tls"
+	// SecretProfileOpaqueLegacy stores the certificates in an Opaque secret under ca, cert and key, as older releases did.
+	// The ca key is not stored.
+	SecretProfileOpaqueLegacy SecretProfile = "opaque-legacy"
+	// SecretProfileCustom uses the configured type and key names.
+	SecretProfileCustom SecretProfile = "custom"
+)
+
+// SecretLayout is the type and the key names of the secret holding the certificates.
+type SecretLayout struct {
+	Type string
+	Keys SecretKeys
+}
+
+// Layout returns the layout of the profile. The ca key name of custom overrides the one of the profile, if set.
+// For SecretProfileCustom, the other fields set in custom are used and the rest default to SecretProfileTLS.
+// For the other profiles, the other fields set in custom must match the profile.
+func (p SecretProfile) Layout(custom SecretLayout) (SecretLayout, error) {
+	var layout SecretLayout
+
+	switch p {
+	case SecretProfileTLS, SecretProfileCustom:
+		layout = SecretLayout{Type: string(v1.SecretTypeTLS), Keys: SecretKeys{CA: "ca.crt", Cert: v1.TLSCertKey, Key: v1.TLSPrivateKeyKey, CAKey: "ca.key"}}
+	case SecretProfileOpaqueLegacy:
+		layout = SecretLayout{Type: string(v1.SecretTypeOpaque), Keys: SecretKeys{CA: "ca", Cert: "cert", Key: "key"}}
+	default:
+		return SecretLayout{}, fmt.Errorf("unknown secret profile '%s', must be 'tls', 'opaque-legacy' or 'custom'", p)
+	}
+
+	if custom.Keys.CAKey != "" {
+		layout.Keys.CAKey = custom.Keys.CAKey
+	}
+
+	for _, field := range []struct {
+		name    string
+		profile *string
+		custom  string
+	}{
+		{"secret type", &layout.Type, custom.Type},
+		{"ca key name", &layout.Keys.CA, custom.Keys.CA},
+		{"cert key name", &layout.Keys.Cert, custom.Keys.Cert},
+		{"key key name", &layout.Keys.Key, custom.Keys.Key},
+	} {
+		switch {
+		case field.custom == "" || field.custom == *field.profile:
+		case p == SecretProfileCustom:
+			*field.profile = field.custom
+		default:
+			return SecretLayout{}, fmt.Errorf("%s '%s' conflicts with secret profile '%s' which uses '%s'. Use the secret profile 'custom' instead",
+				field.name, field.custom, p, *field.profile)
+		}
+	}
+
+	return layout, layout.Validate()
+}
+
+// Validate checks that the key names are valid and distinct and that the API server accepts them for the secret type.
+func (l SecretLayout) Validate() error {
+	keys := []string{l.Keys.CA, l.Keys.Cert, l.Keys.Key}
+	if l.Keys.CAKey != "" {
+		keys = append(keys, l.Keys.CAKey)
+	}
+
+	seen := make(map[string]bool, len(keys))
+
+	for _, key := range keys {
+		if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+			return fmt.Errorf("invalid secret key name '%s': %s", key, strings.Join(errs, ", "))
+		}
+
+		if seen[key] {
+			return fmt.Errorf("secret key name '%s' is used more than once", key)
+		}
+
+		seen[key] = true
+	}
+
+	if l.Type == string(v1.SecretTypeTLS) && (l.Keys.Cert != v1.TLSCertKey || l.Keys.Key != v1.TLSPrivateKeyKey) {
+		return fmt.Errorf("secrets of type %s must store the certificate in '%s' and the key in '%s'", v1.SecretTypeTLS, v1.TLSCertKey, v1.TLSPrivateKeyKey)
+	}
+
+	return nil
+}
+
+// IsLegacySecret reports whether the secret exists and has the layout of SecretProfileOpaqueLegacy,
+// i.e. it was created by an older release. It returns false if the secret does not exist.
+func (k *K8s) IsLegacySecret(ctx context.Context, secretName, namespace string) (bool, error) {
+	secret, err := k.clientSet.CoreV1().Secrets(namespace).Get(ctx, secretName, metav1.GetOptions{})
+
+	switch {
+	case k8serrors.IsNotFound(err):
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("error getting secret: %w", err)
+	}
+
+	_, hasCert := secret.Data["cert"]
+	_, hasKey := secret.Data["key"]
+
+	return secretTypeOrDefault(secret.Type) == v1.SecretTypeOpaque && hasCert && hasKey, nil
+}
@@ -0,0 +1,108 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSecretProfileLayout(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		profile  SecretProfile
+		custom   SecretLayout
+		expected SecretLayout
+		err      bool
+	}{
+		"tls": {
+			profile:  SecretProfileTLS,
+			expected: SecretLayout{Type: "kubernetes.io/tls", Keys: SecretKeys{CA: "ca.crt", Cert: "tls.crt", Key: "tls.key", CAKey: "ca.key"}},
+		},
+		"tls_with_ca_key": {
+			profile:  SecretProfileTLS,
+			custom:   SecretLayout{Keys: SecretKeys{CA: "ca.crt", CAKey: "tls-ca.key"}},
+			expected: SecretLayout{Type: "kubernetes.io/tls", Keys: SecretKeys{CA: "ca.crt", Cert: "tls.crt", Key: "tls.key", CAKey: "tls-ca.key"}},
+		},
+		"tls_with_conflicting_key": {
+			profile: SecretProfileTLS,
+			custom:  SecretLayout{Keys: SecretKeys{Cert: "cert"}},
+			err:     true,
+		},
+		"opaque_legacy": {
+			profile:  SecretProfileOpaqueLegacy,
+			expected: SecretLayout{Type: "Opaque", Keys: SecretKeys{CA: "ca", Cert: "cert", Key: "key"}},
+		},
+		"opaque_legacy_with_conflicting_type": {
+			profile: SecretProfileOpaqueLegacy,
+			custom:  SecretLayout{Type: "kubernetes.io/tls"},
+			err:     true,
+		},
+		"custom": {
+			profile:  SecretProfileCustom,
+			custom:   SecretLayout{Type: "Opaque", Keys: SecretKeys{Cert: "server.crt", Key: "server.key"}},
+			expected: SecretLayout{Type: "Opaque", Keys: SecretKeys{CA: "ca.crt", Cert: "server.crt", Key: "server.key", CAKey: "ca.key"}},
+		},
+		"custom_tls_with_invalid_key_names": {
+			profile: SecretProfileCustom,
+			custom:  SecretLayout{Keys: SecretKeys{Cert: "server.crt"}},
+			err:     true,
+		},
+		"custom_with_duplicate_key_names": {
+			profile: SecretProfileCustom,
+			custom:  SecretLayout{Type: "Opaque", Keys: SecretKeys{CA: "ca", Cert: "ca"}},
+			err:     true,
+		},
+		"custom_with_invalid_key_name": {
+			profile: SecretProfileCustom,
+			custom:  SecretLayout{Type: "Opaque", Keys: SecretKeys{CA: "ca/crt"}},
+			err:     true,
+		},
+		"unknown": {
+			profile: "foo",
+			err:     true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			layout, err := tc.profile.Layout(tc.custom)
+			if tc.err {
+				require.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, layout)
+		})
+	}
+}
+
+func TestIsLegacySecret(t *testing.T) {
+	t.Parallel()
+
+	k := newTestSimpleK8s(
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "legacy", Namespace: testNamespace},
+			Type:       v1.SecretTypeOpaque,
+			Data:       map[string][]byte{"ca": []byte("ca"), "cert": []byte("cert"), "key": []byte("key")},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "tls", Namespace: testNamespace},
+			Type:       v1.SecretTypeTLS,
+			Data:       map[string][]byte{"ca.crt": []byte("ca"), "tls.crt": []byte("cert"), "tls.key": []byte("key")},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "empty", Namespace: testNamespace},
+		},
+	)
+
+	for name, expected := range map[string]bool{"legacy": true, "tls": false, "empty": false, "missing": false} {
+		legacy, err := k.IsLegacySecret(contextWithDeadline(t), name, testNamespace)
+		require.NoError(t, err)
+		require.Equal(t, expected, legacy, name)
+	}
+}
@@ -0,0 +1,88 @@
+package k8s
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// CARolloverPhaseAnnotation holds the RolloverPhase of the secret.
+	CARolloverPhaseAnnotation = "certgen.io/ca-rollover-phase"
+	// CARolloverSinceAnnotation holds the point in time the current RolloverPhase began, in RFC 3339 format.
+	CARolloverSinceAnnotation = "certgen.io/ca-rollover-since"
+	// CARolloverGracePeriodAnnotation holds the grace period of each RolloverPhase, in the format of time.ParseDuration.
+	CARolloverGracePeriodAnnotation = "certgen.io/ca-rollover-grace-period"
+)
+
+// RolloverPhase is a phase of an overlapping ca rollover.
+type RolloverPhase string
+
+const (
+	// RolloverPhaseCABundled means a new ca was generated and the ca holds the new ca followed by the old ca.
+	// The certificate is still issued by the old ca.
+	RolloverPhaseCABundled RolloverPhase = "ca-bundled"
+	// RolloverPhaseLeafRotated means the certificate is issued by the new ca. The ca still holds both cas.
+	RolloverPhaseLeafRotated RolloverPhase = "leaf-rotated"
+	// RolloverPhaseCompleted means the old ca was dropped from the ca.
+	RolloverPhaseCompleted RolloverPhase = "completed"
+)
+
+// Rollover is the state of an overlapping ca rollover. It is stored in annotations of the secret.
+type Rollover struct {
+	Phase RolloverPhase
+	Since time.Time
+	// GracePeriod is the grace period the rollover was started with. It is zero if unknown.
+	GracePeriod time.Duration
+}
+
+// InProgress reports whether the rollover is in a phase that still holds the old ca.
+func (r *Rollover) InProgress() bool {
+	return r != nil && (r.Phase == RolloverPhaseCABundled || r.Phase == RolloverPhaseLeafRotated)
+}
+
+// RolloverAnnotations returns the annotations representing rollover. It returns nil if rollover is nil.
+func RolloverAnnotations(rollover *Rollover) map[string]string {
+	if rollover == nil {
+		return nil
+	}
+
+	annotations := map[string]string{
+		CARolloverPhaseAnnotation: string(rollover.Phase),
+		CARolloverSinceAnnotation: rollover.Since.UTC().Format(time.RFC3339),
+	}
+
+	if rollover.GracePeriod > 0 {
+		annotations[CARolloverGracePeriodAnnotation] = rollover.GracePeriod.String()
+	}
+
+	return annotations
+}
+
+// ParseRollover returns the rollover represented by annotations. It returns nil if annotations hold no rollover.
+func ParseRollover(annotations map[string]string) (*Rollover, error) {
+	phase, ok := annotations[CARolloverPhaseAnnotation]
+	if !ok {
+		return nil, nil //nolint:nilnil
+	}
+
+	since, err := time.Parse(time.RFC3339, annotations[CARolloverSinceAnnotation])
+	if err != nil {
+		return nil, fmt.Errorf("invalid annotation %s: %w", CARolloverSinceAnnotation, err)
+	}
+
+	switch RolloverPhase(phase) {
+	case RolloverPhaseCABundled, RolloverPhaseLeafRotated, RolloverPhaseCompleted:
+	default:
+		return nil, fmt.Errorf("invalid annotation %s: unknown phase '%s'", CARolloverPhaseAnnotation, phase)
+	}
+
+	var gracePeriod time.Duration
+
+	if value, ok := annotations[CARolloverGracePeriodAnnotation]; ok {
+		if gracePeriod, err = time.ParseDuration(value); err != nil {
+			return nil, fmt.Errorf("invalid annotation %s: %w", CARolloverGracePeriodAnnotation, err)
+		}
+	}
+
+	return &Rollover{Phase: RolloverPhase(phase), Since: since, GracePeriod: gracePeriod}, nil
+}
@@ -0,0 +1,70 @@
+package k8s
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSaveAndLoadRollover(t *testing.T) {
+	t.Parallel()
+
+	k := newTestSimpleK8s()
+	ctx := contextWithDeadline(t)
+	ca, cert, key := genSecretData()
+
+	since := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	certs := &Certificates{CA: ca, Cert: cert, Key: key, Rollover: &Rollover{Phase: RolloverPhaseCABundled, Since: since}}
+	metadata := SecretMetadata{Annotations: map[string]string{"example.com/owner": "team"}}
+
+	require.NoError(t, k.SaveCertsToSecret(ctx, testSecretName, testSecretType, testNamespace, testSecretKeys, metadata, certs))
+
+	secret, err := k.clientSet.CoreV1().Secrets(testNamespace).Get(ctx, testSecretName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"example.com/owner":       "team",
+		CARolloverPhaseAnnotation: "ca-bundled",
+		CARolloverSinceAnnotation: "2026-01-02T03:04:05Z",
+	}, secret.Annotations)
+	require.Equal(t, map[string]string{"example.com/owner": "team"}, metadata.Annotations)
+
+	loaded, err := k.GetCertsFromSecret(ctx, testSecretName, testNamespace, testSecretKeys)
+	require.NoError(t, err)
+	require.Equal(t, certs, loaded)
+
+	certs.Rollover = &Rollover{Phase: RolloverPhaseLeafRotated, Since: since.Add(time.Hour)}
+
+	diff, err := k.DiffSecret(ctx, testSecretName, testNamespace, testSecretKeys, certs)
+	require.NoError(t, err)
+	require.Equal(t, []Change{
+		{Field: "metadata.annotations[" + CARolloverPhaseAnnotation + "]", Old: "ca-bundled", New: "leaf-rotated"},
+		{Field: "metadata.annotations[" + CARolloverSinceAnnotation + "]", Old: "2026-01-02T03:04:05Z", New: "2026-01-02T04:04:05Z"},
+	}, diff.Changes)
+}
+
+func TestLoadInvalidRollover(t *testing.T) {
+	t.Parallel()
+
+	ca, cert, key := genSecretData()
+
+	for name, annotations := range map[string]map[string]string{
+		"unknown_phase": {CARolloverPhaseAnnotation: "foo", CARolloverSinceAnnotation: "2026-01-02T03:04:05Z"},
+		"invalid_since": {CARolloverPhaseAnnotation: "ca-bundled", CARolloverSinceAnnotation: "yesterday"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			k := newTestSimpleK8s(&v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: testSecretName, Namespace: testNamespace, Annotations: annotations},
+				Type:       testSecretType,
+				Data:       map[string][]byte{"ca.crt": ca, "tls.crt": cert, "tls.key": key},
+			})
+
+			_, err := k.GetCertsFromSecret(contextWithDeadline(t), testSecretName, testNamespace, testSecretKeys)
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,232 @@
+// Package metrics collects the certificate expiry and patch outcomes of kube-webhook-certgen
+// and exposes them in the Prometheus text format.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jkroepke/kube-webhook-certgen/pkg/certs"
+	"github.com/jkroepke/kube-webhook-certgen/pkg/k8s"
+)
+
+// contentType is the content type of the Prometheus text format.
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// shutdownTimeout is the time Serve waits for open requests after ctx is cancelled.
+const shutdownTimeout = 5 * time.Second
+
+// Metrics holds the metrics of a process. It is safe for concurrent use.
+// All methods of a nil *Metrics do nothing, so recording can be disabled by passing nil.
+type Metrics struct {
+	mu                      sync.Mutex
+	caNotAfter              time.Time
+	certNotAfter            time.Time
+	lastSuccessfulReconcile time.Time
+	patchedObjects          uint64
+	patchFailures           map[string]uint64
+	stepDurations           map[string]time.Duration
+	run                     *runOutcome
+}
+
+// runOutcome is the outcome of a command, see RunFinished.
+type runOutcome struct {
+	succeeded bool
+	finished  time.Time
+	duration  time.Duration
+}
+
+// New returns empty metrics.
+func New() *Metrics {
+	return &Metrics{patchFailures: map[string]uint64{}, stepDurations: map[string]time.Duration{}}
+}
+
+// ObserveCertificates records the expiry of the ca and the certificate. The certificates are parsed as by create,
+// so the ca is the one that issued the certificate if ca holds multiple certificates.
+func (m *Metrics) ObserveCertificates(ca, cert, key []byte) error {
+	if m == nil {
+		return nil
+	}
+
+	bundle, err := certs.ParseBundle(ca, cert, key)
+	if err != nil {
+		return fmt.Errorf("failed to parse certificates: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.caNotAfter = bundle.CA.NotAfter
+	m.certNotAfter = bundle.Cert.NotAfter
+
+	return nil
+}
+
+// ObservePatchResult counts the patched objects and the failures by kind.
+func (m *Metrics) ObservePatchResult(result k8s.PatchResult) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, object := range result {
+		if object.Err != nil {
+			m.patchFailures[object.Kind]++
+
+			continue
+		}
+
+		m.patchedObjects++
+	}
+}
+
+// ReconcileSucceeded records the current time as the time of the last successful reconciliation.
+func (m *Metrics) ReconcileSucceeded() {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastSuccessfulReconcile = time.Now()
+}
+
+// ObserveStep records the duration of the last execution of step, e.g. create or patch.
+func (m *Metrics) ObserveStep(step string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stepDurations[step] = duration
+}
+
+// RunFinished records the outcome of a command which ran for duration. err is the error the command failed with.
+func (m *Metrics) RunFinished(err error, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.run = &runOutcome{succeeded: err == nil, finished: time.Now(), duration: duration}
+}
+
+// WriteTo writes the metrics in the Prometheus text format to w.
+// Timestamps which were not observed yet are omitted.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+
+	writeTimestamp(&b, "kube_webhook_certgen_ca_not_after_timestamp_seconds",
+		"Point in time at which the ca expires, in seconds since the epoch.", m.caNotAfter)
+	writeTimestamp(&b, "kube_webhook_certgen_cert_not_after_timestamp_seconds",
+		"Point in time at which the certificate expires, in seconds since the epoch.", m.certNotAfter)
+	writeTimestamp(&b, "kube_webhook_certgen_last_successful_reconcile_timestamp_seconds",
+		"Point in time of the last successful reconciliation, in seconds since the epoch.", m.lastSuccessfulReconcile)
+
+	writeHeader(&b, "kube_webhook_certgen_patched_objects_total", "Number of objects patched with the ca.", "counter")
+	fmt.Fprintf(&b, "kube_webhook_certgen_patched_objects_total %d\n", m.patchedObjects)
+
+	writeHeader(&b, "kube_webhook_certgen_patch_failures_total", "Number of objects which failed to patch, by kind.", "counter")
+
+	for _, kind := range slices.Sorted(maps.Keys(m.patchFailures)) {
+		fmt.Fprintf(&b, "kube_webhook_certgen_patch_failures_total{kind=\"%s\"} %d\n", escapeLabelValue(kind), m.patchFailures[kind])
+	}
+
+	if len(m.stepDurations) > 0 {
+		writeHeader(&b, "kube_webhook_certgen_step_duration_seconds", "Duration of the last execution of a step, by step.", "gauge")
+
+		for _, step := range slices.Sorted(maps.Keys(m.stepDurations)) {
+			fmt.Fprintf(&b, "kube_webhook_certgen_step_duration_seconds{step=\"%s\"} %g\n", escapeLabelValue(step), m.stepDurations[step].Seconds())
+		}
+	}
+
+	if m.run != nil {
+		succeeded := 0
+		if m.run.succeeded {
+			succeeded = 1
+		}
+
+		writeHeader(&b, "kube_webhook_certgen_run_success", "Whether the command succeeded (1) or failed (0).", "gauge")
+		fmt.Fprintf(&b, "kube_webhook_certgen_run_success %d\n", succeeded)
+		writeHeader(&b, "kube_webhook_certgen_run_duration_seconds", "Duration of the command.", "gauge")
+		fmt.Fprintf(&b, "kube_webhook_certgen_run_duration_seconds %g\n", m.run.duration.Seconds())
+		writeTimestamp(&b, "kube_webhook_certgen_run_finished_timestamp_seconds",
+			"Point in time at which the command finished, in seconds since the epoch.", m.run.finished)
+	}
+
+	n, err := io.WriteString(w, b.String())
+
+	return int64(n), err //nolint:wrapcheck
+}
+
+// Handler returns a handler serving the metrics.
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+
+		if _, err := m.WriteTo(w); err != nil {
+			slog.Debug("failed to write metrics", slog.Any("err", err))
+		}
+	})
+}
+
+// Serve serves the metrics on /metrics of listener until ctx is cancelled.
+func (m *Metrics) Serve(ctx context.Context, listener net.Listener) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: shutdownTimeout}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+		defer cancel()
+
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve metrics: %w", err)
+	}
+
+	return nil
+}
+
+func writeHeader(b *strings.Builder, name, help, metricType string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+func writeTimestamp(b *strings.Builder, name, help string, t time.Time) {
+	if t.IsZero() {
+		return
+	}
+
+	writeHeader(b, name, help, "gauge")
+	fmt.Fprintf(b, "%s %d\n", name, t.Unix())
+}
+
+// escapeLabelValue escapes a label value as required by the Prometheus text format.
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,179 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jkroepke/kube-webhook-certgen/pkg/certs"
+	"github.com/jkroepke/kube-webhook-certgen/pkg/k8s"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	t.Run("empty", func(t *testing.T) {
+		t.Parallel()
+
+		var b strings.Builder
+
+		_, err := New().WriteTo(&b)
+		require.NoError(t, err)
+		require.NotContains(t, b.String(), "not_after")
+		require.NotContains(t, b.String(), "last_successful_reconcile")
+		require.Contains(t, b.String(), "kube_webhook_certgen_patched_objects_total 0\n")
+		require.Contains(t, b.String(), "# TYPE kube_webhook_certgen_patch_failures_total counter\n")
+	})
+
+	t.Run("observed", func(t *testing.T) {
+		t.Parallel()
+
+		options := certs.DefaultOptions()
+		options.LeafLifetime = 24 * time.Hour
+
+		ca, cert, key, err := certs.GenerateCerts("localhost", options)
+		require.NoError(t, err)
+
+		bundle, err := certs.ParseBundle(ca, cert, key)
+		require.NoError(t, err)
+
+		m := New()
+		require.NoError(t, m.ObserveCertificates(ca, cert, key))
+		m.ObservePatchResult(k8s.PatchResult{
+			{Kind: "APIService", Name: "a"},
+			{Kind: "ValidatingWebhookConfiguration", Name: "b"},
+			{Kind: "ValidatingWebhookConfiguration", Name: "c", Err: errors.New("conflict")},
+		})
+		m.ObservePatchResult(k8s.PatchResult{{Kind: "CustomResourceDefinition", Name: "d", Err: errors.New("not found")}})
+		m.ReconcileSucceeded()
+
+		var b strings.Builder
+
+		_, err = m.WriteTo(&b)
+		require.NoError(t, err)
+		require.Contains(t, b.String(), fmt.Sprintf("kube_webhook_certgen_ca_not_after_timestamp_seconds %d\n", bundle.CA.NotAfter.Unix()))
+		require.Contains(t, b.String(), fmt.Sprintf("kube_webhook_certgen_cert_not_after_timestamp_seconds %d\n", bundle.Cert.NotAfter.Unix()))
+		require.Contains(t, b.String(), "kube_webhook_certgen_last_successful_reconcile_timestamp_seconds ")
+		require.Contains(t, b.String(), "kube_webhook_certgen_patched_objects_total 2\n")
+		require.Contains(t, b.String(), `kube_webhook_certgen_patch_failures_total{kind="CustomResourceDefinition"} 1`+"\n"+
+			`kube_webhook_certgen_patch_failures_total{kind="ValidatingWebhookConfiguration"} 1`+"\n")
+	})
+
+	t.Run("invalid_certificates", func(t *testing.T) {
+		t.Parallel()
+
+		require.Error(t, New().ObserveCertificates([]byte("ca"), []byte("cert"), []byte("key")))
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		t.Parallel()
+
+		var m *Metrics
+
+		require.NoError(t, m.ObserveCertificates(nil, nil, nil))
+		m.ObservePatchResult(k8s.PatchResult{{Kind: "APIService", Name: "a"}})
+		m.ReconcileSucceeded()
+	})
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	m := New()
+	m.ObservePatchResult(k8s.PatchResult{{Kind: "APIService", Name: "a"}})
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, contentType, rec.Header().Get("Content-Type"))
+	require.Contains(t, rec.Body.String(), "kube_webhook_certgen_patched_objects_total 1\n")
+}
+
+func TestServe(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error)
+
+	go func() { done <- New().Serve(ctx, listener) }()
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, "http://"+listener.Addr().String()+"/metrics", nil)
+	require.NoError(t, err)
+
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Contains(t, string(body), "kube_webhook_certgen_patched_objects_total 0\n")
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestPush(t *testing.T) {
+	t.Parallel()
+
+	t.Run("replaces_group", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			method, path, contentTypeHeader string
+			body                            []byte
+		)
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			method, path, contentTypeHeader = r.Method, r.URL.EscapedPath(), r.Header.Get("Content-Type")
+			body, _ = io.ReadAll(r.Body)
+
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		m := New()
+		m.ObserveStep("create", 1500*time.Millisecond)
+		m.RunFinished(errors.New("failed"), 2*time.Second)
+
+		err := m.Push(t.Context(), server.Client(), server.URL+"/", "certgen", map[string]string{"namespace": "default", "command": "create"})
+		require.NoError(t, err)
+		require.Equal(t, http.MethodPut, method)
+		require.Equal(t, "/metrics/job/certgen/command/create/namespace/default", path)
+		require.Equal(t, contentType, contentTypeHeader)
+		require.Contains(t, string(body), "# TYPE kube_webhook_certgen_run_success gauge\nkube_webhook_certgen_run_success 0\n")
+		require.Contains(t, string(body), "kube_webhook_certgen_run_duration_seconds 2\n")
+		require.Contains(t, string(body), "kube_webhook_certgen_run_finished_timestamp_seconds ")
+		require.Contains(t, string(body), `kube_webhook_certgen_step_duration_seconds{step="create"} 1.5`+"\n")
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		t.Parallel()
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer server.Close()
+
+		err := New().Push(t.Context(), server.Client(), server.URL, "certgen", nil)
+		require.ErrorContains(t, err, "unexpected status 400")
+	})
+
+	t.Run("no_job", func(t *testing.T) {
+		t.Parallel()
+
+		require.Error(t, New().Push(t.Context(), http.DefaultClient, "http://localhost", "", nil))
+	})
+}
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Push replaces the metrics of the group job and grouping at the Pushgateway gatewayURL with m.
+// The group is addressed as /metrics/job/<job>/<label>/<value>/..., with the grouping labels sorted by name.
+func (m *Metrics) Push(ctx context.Context, client *http.Client, gatewayURL, job string, grouping map[string]string) error {
+	if job == "" {
+		return fmt.Errorf("no job given for pushing metrics to '%s'", gatewayURL)
+	}
+
+	target := strings.TrimSuffix(gatewayURL, "/") + "/metrics/job/" + url.PathEscape(job)
+	for _, name := range slices.Sorted(maps.Keys(grouping)) {
+		target += "/" + url.PathEscape(name) + "/" + url.PathEscape(grouping[name])
+	}
+
+	var body strings.Builder
+
+	if _, err := m.WriteTo(&body); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, strings.NewReader(body.String()))
+	if err != nil {
+		return fmt.Errorf("invalid pushgateway url '%s': %w", gatewayURL, err)
+	}
+
+	req.Header.Set("Content-Type", contentType)
+
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to push metrics to '%s': %w", target, err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("failed to push metrics to '%s': unexpected status %s", target, res.Status)
+	}
+
+	return nil
+}
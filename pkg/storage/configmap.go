@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jkroepke/kube-webhook-certgen/pkg/k8s"
+)
+
+// ConfigMap stores only the ca in a ConfigMap, e.g. to distribute it to clients of the webhook.
+type ConfigMap struct {
+	k         *k8s.K8s
+	name      string
+	namespace string
+	keys      []string
+}
+
+// NewConfigMap returns a store for the ConfigMap name in namespace. The ca is stored under each of keys
+// and loaded from the first one.
+func NewConfigMap(k *k8s.K8s, name, namespace string, keys []string) *ConfigMap {
+	return &ConfigMap{k: k, name: name, namespace: namespace, keys: keys}
+}
+
+// Load is not supported, as the ConfigMap does not hold the certificate and key.
+func (c *ConfigMap) Load(_ context.Context) (*k8s.Certificates, error) {
+	return nil, fmt.Errorf("ConfigMap '%s' in namespace '%s' stores only the ca: %w", c.name, c.namespace, errors.ErrUnsupported)
+}
+
+// LoadCA returns the ca stored in the ConfigMap.
+func (c *ConfigMap) LoadCA(ctx context.Context) ([]byte, error) {
+	if len(c.keys) == 0 {
+		return nil, errors.New("no ConfigMap key defined")
+	}
+
+	ca, err := c.k.GetCaFromConfigMap(ctx, c.keys[0], c.name, c.namespace)
+	if errors.Is(err, k8s.ErrNoConfigMap) {
+		return nil, ErrNotFound
+	}
+
+	return ca, err //nolint:wrapcheck
+}
+
+// Save creates the ConfigMap or replaces the ca in it. Other keys of the ConfigMap are kept.
+func (c *ConfigMap) Save(ctx context.Context, certs *k8s.Certificates) error {
+	return c.k.SaveCaToConfigMap(ctx, c.keys, c.name, c.namespace, certs.CA) //nolint:wrapcheck
+}
+
+// Diff returns the changes Save would apply to the ConfigMap.
+func (c *ConfigMap) Diff(ctx context.Context, certs *k8s.Certificates) (k8s.ObjectDiff, error) {
+	if certs == nil {
+		return k8s.ObjectDiff{Kind: "ConfigMap", Namespace: c.namespace, Name: c.name}, nil
+	}
+
+	return c.k.DiffConfigMap(ctx, c.keys, c.name, c.namespace, certs.CA) //nolint:wrapcheck
+}
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jkroepke/kube-webhook-certgen/pkg/files"
+	"github.com/jkroepke/kube-webhook-certgen/pkg/k8s"
+)
+
+// Files stores the bundle as files in a directory. It does not need API server access.
+type Files struct {
+	options files.Options
+}
+
+// NewFiles returns a store for the files described by options.
+func NewFiles(options files.Options) *Files {
+	return &Files{options: options}
+}
+
+// Load returns the bundle stored in the files.
+func (f *Files) Load(_ context.Context) (*k8s.Certificates, error) {
+	certs, err := files.Read(f.options)
+	if errors.Is(err, files.ErrNoFiles) {
+		return nil, ErrNotFound
+	}
+
+	return certs, err //nolint:wrapcheck
+}
+
+// LoadCA returns the ca stored in the ca file.
+func (f *Files) LoadCA(_ context.Context) ([]byte, error) {
+	ca, err := files.ReadCA(f.options)
+	if errors.Is(err, files.ErrNoFiles) {
+		return nil, ErrNotFound
+	}
+
+	return ca, err //nolint:wrapcheck
+}
+
+// Save writes the ca, the certificate and the key files, and the ca key and the rollover state if set.
+func (f *Files) Save(_ context.Context, certs *k8s.Certificates) error {
+	return files.Write(f.options, certs) //nolint:wrapcheck
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jkroepke/kube-webhook-certgen/pkg/k8s"
+)
+
+// Secret stores the bundle in a Secret.
+type Secret struct {
+	k          *k8s.K8s
+	name       string
+	secretType string
+	namespace  string
+	keys       k8s.SecretKeys
+	metadata   k8s.SecretMetadata
+}
+
+// NewSecret returns a store for the Secret name in namespace. keys are the names of the data keys,
+// metadata is added to the Secret on save.
+func NewSecret(k *k8s.K8s, name, secretType, namespace string, keys k8s.SecretKeys, metadata k8s.SecretMetadata) *Secret {
+	return &Secret{k: k, name: name, secretType: secretType, namespace: namespace, keys: keys, metadata: metadata}
+}
+
+// Load returns the bundle stored in the Secret.
+func (s *Secret) Load(ctx context.Context) (*k8s.Certificates, error) {
+	certs, err := s.k.GetCertsFromSecret(ctx, s.name, s.namespace, s.keys)
+	if errors.Is(err, k8s.ErrNoSecret) || errors.Is(err, k8s.ErrNoCertificates) {
+		return nil, ErrNotFound
+	}
+
+	return certs, err //nolint:wrapcheck
+}
+
+// LoadCA returns the ca stored in the Secret.
+func (s *Secret) LoadCA(ctx context.Context) ([]byte, error) {
+	ca, err := s.k.GetCaFromSecret(ctx, s.keys.CA, s.name, s.namespace)
+	if errors.Is(err, k8s.ErrNoSecret) {
+		return nil, ErrNotFound
+	}
+
+	return ca, err //nolint:wrapcheck
+}
+
+// Save creates the Secret or replaces its data.
+func (s *Secret) Save(ctx context.Context, certs *k8s.Certificates) error {
+	return s.k.SaveCertsToSecret(ctx, s.name, s.secretType, s.namespace, s.keys, s.metadata, certs) //nolint:wrapcheck
+}
+
+// Diff returns the changes Save would apply to the Secret.
+func (s *Secret) Diff(ctx context.Context, certs *k8s.Certificates) (k8s.ObjectDiff, error) {
+	if certs == nil {
+		return k8s.ObjectDiff{Kind: "Secret", Namespace: s.namespace, Name: s.name}, nil
+	}
+
+	return s.k.DiffSecret(ctx, s.name, s.namespace, s.keys, certs) //nolint:wrapcheck
+}
+
+// RecordEvent records an Event on the Secret.
+func (s *Secret) RecordEvent(ctx context.Context, eventType, reason, message string) {
+	s.k.RecordSecretEvent(ctx, s.name, s.namespace, eventType, reason, message)
+}
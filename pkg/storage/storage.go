@@ -0,0 +1,35 @@
+// Package storage loads and saves certificate material in different backends, e.g. a Secret or local files.
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jkroepke/kube-webhook-certgen/pkg/k8s"
+)
+
+// ErrNotFound is returned if a store does not hold any certificates yet.
+var ErrNotFound = errors.New("no certificates stored")
+
+// Store loads and saves a ca, certificate and key bundle.
+type Store interface {
+	// Load returns the stored bundle, or ErrNotFound if nothing is stored.
+	Load(ctx context.Context) (*k8s.Certificates, error)
+	// LoadCA returns the stored ca, or ErrNotFound if nothing is stored.
+	LoadCA(ctx context.Context) ([]byte, error)
+	// Save stores the bundle. Stores which keep only a part of the bundle ignore the rest.
+	Save(ctx context.Context, certs *k8s.Certificates) error
+}
+
+// Differ is implemented by stores which can describe the changes Save would apply.
+type Differ interface {
+	// Diff returns the changes Save would apply for certs. If certs is nil, the stored bundle is kept
+	// and the diff has no changes.
+	Diff(ctx context.Context, certs *k8s.Certificates) (k8s.ObjectDiff, error)
+}
+
+// EventRecorder is implemented by stores which can record Kubernetes Events on the stored object.
+type EventRecorder interface {
+	// RecordEvent records an Event of eventType, e.g. Normal or Warning, with reason and message.
+	RecordEvent(ctx context.Context, eventType, reason, message string)
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/jkroepke/kube-webhook-certgen/pkg/files"
+	"github.com/jkroepke/kube-webhook-certgen/pkg/k8s"
+	"github.com/stretchr/testify/require"
+	apiextensionsfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	aggregatorfake "k8s.io/kube-aggregator/pkg/client/clientset_generated/clientset/fake"
+)
+
+const testNamespace = "default"
+
+var testCerts = &k8s.Certificates{CA: []byte("ca"), Cert: []byte("cert"), Key: []byte("key")}
+
+func newTestK8s(t *testing.T) *k8s.K8s {
+	t.Helper()
+
+	k, err := k8s.New(
+		fake.NewClientset(), aggregatorfake.NewSimpleClientset(), apiextensionsfake.NewSimpleClientset(), dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()),
+	)
+	require.NoError(t, err)
+
+	return k
+}
+
+func TestStores(t *testing.T) {
+	t.Parallel()
+
+	for name, newStore := range map[string]func(t *testing.T) Store{
+		"secret": func(t *testing.T) Store {
+			t.Helper()
+
+			return NewSecret(newTestK8s(t), "webhook-certs", "kubernetes.io/tls", testNamespace, k8s.SecretKeys{CA: "ca.crt", Cert: "tls.crt", Key: "tls.key"}, k8s.SecretMetadata{})
+		},
+		"files": func(t *testing.T) Store {
+			t.Helper()
+
+			return NewFiles(files.DefaultOptions(filepath.Join(t.TempDir(), "certs")))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			store := newStore(t)
+
+			_, err := store.Load(ctx)
+			require.ErrorIs(t, err, ErrNotFound)
+
+			_, err = store.LoadCA(ctx)
+			require.ErrorIs(t, err, ErrNotFound)
+
+			require.NoError(t, store.Save(ctx, testCerts))
+
+			certs, err := store.Load(ctx)
+			require.NoError(t, err)
+			require.Equal(t, testCerts, certs)
+
+			ca, err := store.LoadCA(ctx)
+			require.NoError(t, err)
+			require.Equal(t, testCerts.CA, ca)
+		})
+	}
+}
+
+func TestSecretDiff(t *testing.T) {
+	t.Parallel()
+
+	store := NewSecret(newTestK8s(t), "webhook-certs", "kubernetes.io/tls", testNamespace, k8s.SecretKeys{CA: "ca.crt", Cert: "tls.crt", Key: "tls.key"}, k8s.SecretMetadata{})
+
+	diff, err := store.Diff(context.Background(), testCerts)
+	require.NoError(t, err)
+	require.Len(t, diff.Changes, 3)
+
+	diff, err = store.Diff(context.Background(), nil)
+	require.NoError(t, err)
+	require.Empty(t, diff.Changes)
+	require.Equal(t, "webhook-certs", diff.Name)
+}
+
+func TestConfigMapDiff(t *testing.T) {
+	t.Parallel()
+
+	var store Differ = NewConfigMap(newTestK8s(t), "webhook-ca", testNamespace, []string{"ca.crt", "ca.pem"})
+
+	diff, err := store.Diff(context.Background(), testCerts)
+	require.NoError(t, err)
+	require.Len(t, diff.Changes, 2)
+	require.Equal(t, testNamespace, diff.Namespace)
+
+	diff, err = store.Diff(context.Background(), nil)
+	require.NoError(t, err)
+	require.Empty(t, diff.Changes)
+	require.Equal(t, "webhook-ca", diff.Name)
+}
+
+func TestConfigMap(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := NewConfigMap(newTestK8s(t), "webhook-ca", testNamespace, []string{"ca.crt"})
+
+	_, err := store.LoadCA(ctx)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, store.Save(ctx, testCerts))
+
+	ca, err := store.LoadCA(ctx)
+	require.NoError(t, err)
+	require.Equal(t, testCerts.CA, ca)
+
+	_, err = store.Load(ctx)
+	require.ErrorIs(t, err, errors.ErrUnsupported)
+
+	diff, err := store.Diff(ctx, testCerts)
+	require.NoError(t, err)
+	require.Empty(t, diff.Changes)
+	require.Equal(t, "ConfigMap", diff.Kind)
+}
+
+func TestWriter(t *testing.T) {
+	t.Parallel()
+
+	var b bytes.Buffer
+
+	ctx := context.Background()
+	store := NewWriter(&b)
+
+	_, err := store.Load(ctx)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, store.Save(ctx, testCerts))
+	require.Equal(t, "cacertkey", b.String())
+}
@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/jkroepke/kube-webhook-certgen/pkg/k8s"
+)
+
+// Writer prints the bundle PEM encoded, e.g. to stdout. It never holds any certificates,
+// so certificates are generated on every run.
+type Writer struct {
+	w io.Writer
+}
+
+// NewWriter returns a store printing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Load always returns ErrNotFound.
+func (w *Writer) Load(_ context.Context) (*k8s.Certificates, error) {
+	return nil, ErrNotFound
+}
+
+// LoadCA always returns ErrNotFound.
+func (w *Writer) LoadCA(_ context.Context) ([]byte, error) {
+	return nil, ErrNotFound
+}
+
+// Save prints the ca, the certificate and the key, in this order.
+func (w *Writer) Save(_ context.Context, certs *k8s.Certificates) error {
+	for _, data := range [][]byte{certs.CA, certs.Cert, certs.Key} {
+		if _, err := w.w.Write(data); err != nil {
+			return fmt.Errorf("failed to print certificates: %w", err)
+		}
+	}
+
+	return nil
+}